@@ -4,11 +4,12 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
-	"net/http"
 
 	"github.com/docker/go-plugins-helpers/sdk"
 )
 
+//go:generate go run ../cmd/protocolgen
+
 const (
 	// AuthZApiRequest is the url for daemon request authorization
 	AuthZApiRequest = "AuthZPlugin.AuthZReq"
@@ -80,6 +81,9 @@ type Request struct {
 
 	// ResponseHeaders stores the response headers sent to the docker daemon
 	ResponseHeaders map[string]string `json:"ResponseHeaders,omitempty"`
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // Response represents authZ plugin response
@@ -94,12 +98,6 @@ type Response struct {
 	Err string `json:"Err,omitempty"`
 }
 
-// Plugin represent the interface a plugin must fulfill.
-type Plugin interface {
-	AuthZReq(Request) Response
-	AuthZRes(Request) Response
-}
-
 // Handler forwards requests and responses between the docker daemon and the plugin.
 type Handler struct {
 	plugin Plugin
@@ -112,32 +110,3 @@ func NewHandler(plugin Plugin) *Handler {
 	h.initMux()
 	return h
 }
-
-func (h *Handler) initMux() {
-	h.handle(reqPath, func(req Request) Response {
-		return h.plugin.AuthZReq(req)
-	})
-
-	h.handle(resPath, func(req Request) Response {
-		return h.plugin.AuthZRes(req)
-	})
-}
-
-type actionHandler func(Request) Response
-
-func (h *Handler) handle(name string, actionCall actionHandler) {
-	h.HandleFunc(name, func(w http.ResponseWriter, r *http.Request) {
-		var (
-			req Request
-			d   = json.NewDecoder(r.Body)
-		)
-		d.UseNumber()
-		if err := d.Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
-
-		res := actionCall(req)
-
-		sdk.EncodeResponse(w, res, res.Err != "")
-	})
-}
@@ -0,0 +1,34 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package authorization
+
+import (
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Plugin represent the interface a plugin must fulfill.
+type Plugin interface {
+	AuthZReq(Request) Response
+	AuthZRes(Request) Response
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(reqPath, func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := sdk.DecodeRequest(w, r, &req); err != nil {
+			return
+		}
+		res := h.plugin.AuthZReq(req)
+		sdk.EncodeResponse(w, res, res.Err != "")
+	})
+	h.HandleFunc(resPath, func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := sdk.DecodeRequest(w, r, &req); err != nil {
+			return
+		}
+		res := h.plugin.AuthZRes(req)
+		sdk.EncodeResponse(w, res, res.Err != "")
+	})
+}
@@ -41,7 +41,7 @@ func (p *TestPlugin) AuthZRes(r Request) Response {
 }
 
 func TestActivate(t *testing.T) {
-	response, err := http.Get("http://localhost:32456/Plugin.Activate")
+	response, err := http.Post("http://localhost:32456/Plugin.Activate", sdk.DefaultContentTypeV1_1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,6 +132,36 @@ func TestAuthZRes(t *testing.T) {
 	}
 }
 
+func TestAuthZReqInvalidBody(t *testing.T) {
+	response, err := http.Post(
+		"http://localhost:32456/AuthZPlugin.AuthZReq",
+		sdk.DefaultContentTypeV1_1,
+		strings.NewReader(`{"User":`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, response.StatusCode)
+	}
+
+	var r Response
+	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Err == "" {
+		t.Fatal("Authorization Error should not be empty")
+	}
+
+	if r.Msg != "" {
+		t.Fatal("The plugin should not have been called")
+	}
+}
+
 func TestPeerCertificateMarshalJSON(t *testing.T) {
 	template := &x509.Certificate{
 		IsCA:                  true,
@@ -0,0 +1,38 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package authorization
+
+import "github.com/docker/go-plugins-helpers/sdk"
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Plugin, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Plugin = (*Client)(nil)
+
+// AuthZReq calls the AuthZReq method of the plugin.
+// Errors are reported in the Err field of the response.
+func (c *Client) AuthZReq(req Request) Response {
+	var res Response
+	if err := c.client.Call(reqPath, req, &res); err != nil {
+		return Response{Err: err.Error()}
+	}
+	return res
+}
+
+// AuthZRes calls the AuthZRes method of the plugin.
+// Errors are reported in the Err field of the response.
+func (c *Client) AuthZRes(req Request) Response {
+	var res Response
+	if err := c.client.Call(resPath, req, &res); err != nil {
+		return Response{Err: err.Error()}
+	}
+	return res
+}
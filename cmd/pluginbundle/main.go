@@ -0,0 +1,88 @@
+// Command pluginbundle assembles the bundle directory of a managed plugin,
+// to create the plugin from with docker plugin create:
+//
+//	pluginbundle -config config.json -rootfs rootfs.tar.gz -addr myplugin bundle
+//	pluginbundle -config config.json -binary ./myplugin -addr myplugin bundle
+//	docker plugin create myplugin bundle
+//
+// The rootfs is extracted from a tar archive, such as the one exported by
+// docker export, or made of a statically linked binary. The config is
+// validated, and its socket checked against the address the plugin passes
+// to ServeUnix with -addr. With -check, the bundle is only checked.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/bundle"
+)
+
+func main() {
+	config := flag.String("config", "", "plugin config, the entrypoint defaults to the binary with -binary")
+	rootfs := flag.String("rootfs", "", "tar archive of the rootfs, - for the standard input")
+	binary := flag.String("binary", "", "statically linked binary to make a minimal rootfs of")
+	addr := flag.String("addr", "", "address the plugin passes to ServeUnix")
+	check := flag.Bool("check", false, "check the bundle instead of building it")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] bundle\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	if *check {
+		err = bundle.Check(flag.Arg(0), *addr)
+	} else {
+		err = build(flag.Arg(0), *config, *rootfs, *binary, *addr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pluginbundle: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func build(dir, configPath, rootfs, binary, addr string) error {
+	if configPath == "" {
+		return errors.New("-config is required")
+	}
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	var config sdk.PluginConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return fmt.Errorf("%s: %v", configPath, err)
+	}
+
+	bd := bundle.Bundle{Config: &config, Addr: addr}
+	switch {
+	case rootfs != "" && binary != "":
+		return errors.New("-rootfs and -binary are exclusive")
+	case binary != "":
+		bd.Rootfs = bundle.FromBinary(binary)
+	case rootfs != "":
+		var r io.Reader = os.Stdin
+		if rootfs != "-" {
+			f, err := os.Open(rootfs)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		bd.Rootfs = bundle.FromTar(r)
+	default:
+		return errors.New("one of -rootfs or -binary is required")
+	}
+	return bd.Build(dir)
+}
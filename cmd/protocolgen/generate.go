@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+const header = "// Code generated by protocolgen. DO NOT EDIT.\n\n"
+
+// generate returns the generated files of p indexed by name.
+func generate(p *Protocol) (map[string][]byte, error) {
+	files := map[string]func(*Protocol) string{
+		"api_gen.go":      generateAPI,
+		"client_gen.go":   generateClient,
+		"api_gen_test.go": generateTest,
+	}
+	out := make(map[string][]byte, len(files))
+	for name, gen := range files {
+		src, err := format.Source([]byte(gen(p)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		out[name] = src
+	}
+	return out, nil
+}
+
+// comment formats text as a line comment indented with indent.
+func comment(text, indent string) string {
+	var b strings.Builder
+	for _, l := range strings.Split(strings.TrimSpace(text), "\n") {
+		b.WriteString(indent + strings.TrimRight("// "+l, " ") + "\n")
+	}
+	return b.String()
+}
+
+func imports(paths ...string) string {
+	if len(paths) == 1 {
+		return fmt.Sprintf("import %q\n\n", paths[0])
+	}
+	var std, ext []string
+	for _, p := range paths {
+		if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			ext = append(ext, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("import (\n")
+	for _, p := range std {
+		fmt.Fprintf(&b, "\t%q\n", p)
+	}
+	if len(std) > 0 && len(ext) > 0 {
+		b.WriteString("\n")
+	}
+	for _, p := range ext {
+		fmt.Fprintf(&b, "\t%q\n", p)
+	}
+	b.WriteString(")\n\n")
+	return b.String()
+}
+
+const sdkImport = modulePath + "/sdk"
+
+func generateAPI(p *Protocol) string {
+	var b bytes.Buffer
+	b.WriteString(header)
+	fmt.Fprintf(&b, "package %s\n\n", p.Package)
+
+	var paths []string
+	for _, r := range p.Routes {
+		if r.NilResponse != "" {
+			paths = append(paths, "errors")
+			break
+		}
+	}
+	if p.Streams() {
+		paths = append(paths, "io")
+	}
+	b.WriteString(imports(append(paths, "net/http", sdkImport)...))
+
+	b.WriteString(comment(p.Doc, ""))
+	fmt.Fprintf(&b, "type %s interface {\n", p.Interface)
+	for _, r := range p.Routes {
+		if r.Doc != "" {
+			b.WriteString(comment(r.Doc, "\t"))
+		}
+		fmt.Fprintf(&b, "\t%s%s\n", r.Method, r.Signature(""))
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("func (h *Handler) initMux() {\n")
+	for _, r := range p.Routes {
+		fmt.Fprintf(&b, "\th.HandleFunc(%s, func(w http.ResponseWriter, r *http.Request) {\n", r.Path)
+		args := ""
+		if r.Request != "" {
+			args = "req"
+			if r.RawRequest {
+				fmt.Fprintf(&b, "\t\treq := %s\n", r.ZeroRequest())
+				b.WriteString("\t\tif err := req.decodeHTTP(r); err != nil {\n")
+				b.WriteString("\t\t\tsdk.EncodeError(w, sdk.InvalidArgument(err))\n")
+			} else if r.RequestPointer() {
+				fmt.Fprintf(&b, "\t\treq := %s\n", r.ZeroRequest())
+				b.WriteString("\t\tif err := sdk.DecodeRequest(w, r, req); err != nil {\n")
+			} else {
+				fmt.Fprintf(&b, "\t\tvar req %s\n", r.Request)
+				b.WriteString("\t\tif err := sdk.DecodeRequest(w, r, &req); err != nil {\n")
+			}
+			b.WriteString("\t\t\treturn\n\t\t}\n")
+		}
+		call := fmt.Sprintf("h.%s.%s(%s)", p.Field, r.Method, args)
+		switch {
+		case r.ErrorField != "":
+			fmt.Fprintf(&b, "\t\tres := %s\n", call)
+			fmt.Fprintf(&b, "\t\tsdk.EncodeResponse(w, res, res.%s != \"\")\n", r.ErrorField)
+		case r.NoError:
+			fmt.Fprintf(&b, "\t\tsdk.EncodeResponse(w, %s, false)\n", call)
+		case r.Response == "":
+			fmt.Fprintf(&b, "\t\tif err := %s; err != nil {\n", call)
+			b.WriteString("\t\t\tsdk.EncodeError(w, err)\n\t\t\treturn\n\t\t}\n")
+			b.WriteString("\t\tsdk.EncodeResponse(w, struct{}{}, false)\n")
+		case r.Stream():
+			fmt.Fprintf(&b, "\t\tres, err := %s\n", call)
+			b.WriteString("\t\tif err != nil {\n\t\t\tsdk.EncodeError(w, err)\n\t\t\treturn\n\t\t}\n")
+			b.WriteString("\t\tsdk.StreamResponseContext(r.Context(), w, res)\n")
+		default:
+			fmt.Fprintf(&b, "\t\tres, err := %s\n", call)
+			b.WriteString("\t\tif err != nil {\n\t\t\tsdk.EncodeError(w, err)\n\t\t\treturn\n\t\t}\n")
+			if r.NilResponse != "" {
+				b.WriteString("\t\tif res == nil {\n")
+				fmt.Fprintf(&b, "\t\t\tsdk.EncodeError(w, errors.New(%q))\n", r.NilResponse)
+				b.WriteString("\t\t\treturn\n\t\t}\n")
+			}
+			b.WriteString("\t\tsdk.EncodeResponse(w, res, false)\n")
+		}
+		b.WriteString("\t})\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func generateClient(p *Protocol) string {
+	var b bytes.Buffer
+	b.WriteString(header)
+	fmt.Fprintf(&b, "package %s\n\n", p.Package)
+	if p.Streams() {
+		b.WriteString(imports("io", sdkImport))
+	} else {
+		b.WriteString(imports(sdkImport))
+	}
+
+	fmt.Fprintf(&b, "// Client calls the methods of a plugin through an sdk.Client. It implements\n// %s, so it can be used wherever a local driver is expected.\n", p.Interface)
+	b.WriteString("type Client struct {\n\tclient *sdk.Client\n}\n\n")
+	b.WriteString("// NewClient creates a Client sending its requests through c.\n")
+	b.WriteString("func NewClient(c *sdk.Client) *Client {\n\treturn &Client{client: c}\n}\n\n")
+	fmt.Fprintf(&b, "var _ %s = (*Client)(nil)\n", p.Interface)
+
+	for _, r := range p.Routes {
+		req := "nil"
+		if r.Request != "" {
+			req = "req"
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "// %s calls the %s method of the plugin.\n", r.Method, r.Method)
+		switch {
+		case r.NoError:
+			b.WriteString("// Errors are reported as an empty response.\n")
+		case r.ErrorField != "":
+			fmt.Fprintf(&b, "// Errors are reported in the %s field of the response.\n", r.ErrorField)
+		}
+		fmt.Fprintf(&b, "func (c *Client) %s%s {\n", r.Method, r.Signature("req"))
+
+		send := func(res string) string {
+			if r.RawRequest {
+				return fmt.Sprintf("c.client.Send(%s+\"?\"+query.Encode(), body, %s)", r.Path, res)
+			}
+			return fmt.Sprintf("c.client.Call(%s, %s, %s)", r.Path, req, res)
+		}
+		if r.RawRequest {
+			b.WriteString("\tquery, body := req.encodeHTTP()\n")
+		}
+		if r.Response == "" {
+			fmt.Fprintf(&b, "\treturn %s\n}\n", send("nil"))
+			continue
+		}
+		if r.Stream() {
+			fmt.Fprintf(&b, "\treturn c.client.Stream(%s, %s)\n}\n", r.Path, req)
+			continue
+		}
+		res := "res"
+		if r.ResponsePointer() {
+			fmt.Fprintf(&b, "\tres := %s\n", r.ZeroResponse())
+		} else {
+			fmt.Fprintf(&b, "\tvar res %s\n", r.Response)
+			res = "&res"
+		}
+		call := send(res)
+		switch {
+		case r.NoError:
+			fmt.Fprintf(&b, "\tif err := %s; err != nil {\n\t\treturn %s\n\t}\n", call, r.ZeroResponse())
+			b.WriteString("\treturn res\n}\n")
+		case r.ErrorField != "":
+			fmt.Fprintf(&b, "\tif err := %s; err != nil {\n\t\treturn %s{%s: err.Error()}\n\t}\n", call, r.ResponseType(), r.ErrorField)
+			b.WriteString("\treturn res\n}\n")
+		default:
+			fmt.Fprintf(&b, "\tif err := %s; err != nil {\n\t\treturn %s, err\n\t}\n", call, r.NilResponseValue())
+			b.WriteString("\treturn res, nil\n}\n")
+		}
+	}
+	return b.String()
+}
+
+func generateTest(p *Protocol) string {
+	var b bytes.Buffer
+	b.WriteString(header)
+	fmt.Fprintf(&b, "package %s\n\n", p.Package)
+	paths := []string{"errors", "net/http", "testing"}
+	if p.Streams() {
+		paths = []string{"errors", "io", "net/http", "strings", "testing"}
+	}
+	b.WriteString(imports(append(paths, "github.com/docker/go-connections/sockets", sdkImport)...))
+
+	b.WriteString(`// protocolStub records the calls it receives and fails all of them when
+// fail is set.
+type protocolStub struct {
+	calls map[string]int
+	fail  bool
+}
+
+func (s *protocolStub) result(method string) error {
+	s.calls[method]++
+	if s.fail {
+		return sdk.NotFound(errors.New(method + " failed"))
+	}
+	return nil
+}
+`)
+	for _, r := range p.Routes {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "func (s *protocolStub) %s%s {\n", r.Method, r.Signature("req"))
+		switch {
+		case r.NoError:
+			fmt.Fprintf(&b, "\ts.result(%q)\n\treturn %s\n", r.Method, r.ZeroResponse())
+		case r.ErrorField != "":
+			fmt.Fprintf(&b, "\tif err := s.result(%q); err != nil {\n\t\treturn %s{%s: err.Error()}\n\t}\n", r.Method, r.ResponseType(), r.ErrorField)
+			fmt.Fprintf(&b, "\treturn %s\n", r.ZeroResponse())
+		case r.Response == "":
+			fmt.Fprintf(&b, "\treturn s.result(%q)\n", r.Method)
+		case r.Stream():
+			fmt.Fprintf(&b, "\tif err := s.result(%q); err != nil {\n\t\treturn nil, err\n\t}\n", r.Method)
+			fmt.Fprintf(&b, "\treturn io.NopCloser(strings.NewReader(%q)), nil\n", r.Method)
+		default:
+			fmt.Fprintf(&b, "\tif err := s.result(%q); err != nil {\n\t\treturn %s, err\n\t}\n", r.Method, r.NilResponseValue())
+			fmt.Fprintf(&b, "\treturn %s, nil\n", r.ZeroResponse())
+		}
+		b.WriteString("}\n")
+	}
+
+	var usesError, usesErrorField bool
+	for _, r := range p.Routes {
+		usesError = usesError || r.ReturnsError()
+		usesErrorField = usesErrorField || r.ErrorField != ""
+	}
+	if usesError {
+		b.WriteString(`
+func checkProtocolError(t *testing.T, method string, fail bool, err error) {
+	t.Helper()
+	switch {
+	case !fail && err != nil:
+		t.Fatalf("%s: unexpected error: %v", method, err)
+	case fail && !sdk.IsNotFound(err):
+		t.Fatalf("%s: expected a not found error, got %v", method, err)
+	case fail && err.Error() != method+" failed":
+		t.Fatalf("%s: expected error %q, got %q", method, method+" failed", err.Error())
+	}
+}
+`)
+	}
+	if usesErrorField {
+		b.WriteString(`
+func checkProtocolErrorField(t *testing.T, method string, fail bool, msg string) {
+	t.Helper()
+	switch {
+	case !fail && msg != "":
+		t.Fatalf("%s: unexpected error: %s", method, msg)
+	case fail && msg != method+" failed":
+		t.Fatalf("%s: expected error %q, got %q", method, method+" failed", msg)
+	}
+}
+`)
+	}
+
+	fmt.Fprintf(&b, `
+func TestProtocol(t *testing.T) {
+	s := &protocolStub{calls: make(map[string]int)}
+	h := &Handler{%s: s, Handler: sdk.NewHandler(manifest)}
+	h.initMux()
+	l := sockets.NewInmemSocket("protocol", 0)
+	go h.Serve(l)
+	defer l.Close()
+
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	for _, fail := range []bool{false, true} {
+		s.fail = fail
+`, p.Field)
+	for _, r := range p.Routes {
+		req := ""
+		if r.Request != "" {
+			req = r.ZeroRequest()
+		}
+		call := fmt.Sprintf("c.%s(%s)", r.Method, req)
+		switch {
+		case r.NoError:
+			fmt.Fprintf(&b, "\t\tif res := %s; res == nil {\n\t\t\tt.Fatalf(\"%s: unexpected nil response\")\n\t\t}\n", call, r.Method)
+		case r.ErrorField != "":
+			fmt.Fprintf(&b, "\t\tres%s := %s\n", r.Method, call)
+			fmt.Fprintf(&b, "\t\tcheckProtocolErrorField(t, %q, fail, res%s.%s)\n", r.Method, r.Method, r.ErrorField)
+		case r.Response == "":
+			fmt.Fprintf(&b, "\t\tcheckProtocolError(t, %q, fail, %s)\n", r.Method, call)
+		case r.Stream():
+			fmt.Fprintf(&b, "\t\trc%s, err%s := %s\n", r.Method, r.Method, call)
+			fmt.Fprintf(&b, "\t\tcheckProtocolError(t, %q, fail, err%s)\n", r.Method, r.Method)
+			fmt.Fprintf(&b, "\t\tif !fail {\n\t\t\tb, err := io.ReadAll(rc%s)\n\t\t\trc%s.Close()\n", r.Method, r.Method)
+			fmt.Fprintf(&b, "\t\t\tif err != nil || string(b) != %q {\n", r.Method)
+			fmt.Fprintf(&b, "\t\t\t\tt.Fatalf(\"%s: unexpected stream %%q: %%v\", b, err)\n\t\t\t}\n\t\t}\n", r.Method)
+		default:
+			fmt.Fprintf(&b, "\t\t_, err%s := %s\n", r.Method, call)
+			fmt.Fprintf(&b, "\t\tcheckProtocolError(t, %q, fail, err%s)\n", r.Method, r.Method)
+		}
+	}
+	b.WriteString("\t}\n\n")
+
+	b.WriteString("\tfor _, m := range []string{\n")
+	for _, r := range p.Routes {
+		fmt.Fprintf(&b, "\t\t%q,\n", r.Method)
+	}
+	b.WriteString("\t} {\n\t\tif s.calls[m] != 2 {\n\t\t\tt.Fatalf(\"expected 2 calls to %s, got %d\", m, s.calls[m])\n\t\t}\n\t}\n}\n")
+	return b.String()
+}
@@ -0,0 +1,85 @@
+// Command protocolgen generates the plugin side of a docker plugin protocol
+// from its description: the driver interface, the http handlers registered in
+// initMux, a typed client and a test exercising every route through them.
+//
+// It is meant to be run with go generate from the package implementing the
+// protocol, which must declare the Handler type, the manifest constant and
+// the constants holding the path of every route:
+//
+//	//go:generate go run ../cmd/protocolgen
+//
+// The description is read from protocol.json, see Protocol and Route for its
+// format.
+//
+// With -registry, it rather generates the protocols listed by
+// cmd/protocolschema from the descriptions of the packages in its arguments,
+// along the values of their manifest and path constants:
+//
+//	//go:generate go run ../protocolgen -registry ../../volume ../../network
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	spec := flag.String("spec", "protocol.json", "protocol description to generate code from")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "name of the generated package")
+	out := flag.String("out", ".", "directory to write the generated files to")
+	registry := flag.Bool("registry", false, "generate the protocol registry of cmd/protocolschema from the packages in the arguments")
+	flag.Parse()
+
+	var err error
+	if *registry {
+		err = runRegistry(flag.Args(), *out)
+	} else {
+		err = run(*spec, *pkg, *out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protocolgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(spec, pkg, out string) error {
+	if pkg == "" {
+		return fmt.Errorf("package name is required outside of go generate")
+	}
+	p, err := readProtocol(spec, pkg)
+	if err != nil {
+		return err
+	}
+
+	files, err := generate(p)
+	if err != nil {
+		return err
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(out, name), src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readProtocol reads and validates the protocol description spec of the
+// package pkg.
+func readProtocol(spec, pkg string) (*Protocol, error) {
+	b, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, err
+	}
+	var p Protocol
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("%s: %v", spec, err)
+	}
+	p.Package = pkg
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", spec, err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const streamType = "io.ReadCloser"
+
+// Protocol describes the routes of a plugin protocol.
+type Protocol struct {
+	// Package is the name of the generated package. It is not part of the
+	// description, go generate provides it.
+	Package string `json:"-"`
+
+	// Interface is the name of the interface implemented by drivers.
+	Interface string
+	// Doc is the doc comment of Interface, without the comment markers.
+	Doc string
+	// Field is the name of the Handler field holding the driver.
+	Field string
+	// Routes are the methods of the protocol, in the order of the interface.
+	Routes []*Route
+}
+
+// Route describes one method of a plugin protocol.
+type Route struct {
+	// Method is the name of the driver method serving the route.
+	Method string
+	// Path is the name of the constant holding the path of the route.
+	Path string
+	// Doc is the doc comment of Method, if any.
+	Doc string
+	// Request is the type the request body is decoded into, prefixed with
+	// a star when the driver takes a pointer. Empty for routes without a
+	// request body.
+	Request string
+	// Response is the type the driver replies with, prefixed with a star
+	// when it is a pointer. Empty for routes replying with an empty object.
+	// Responses of type io.ReadCloser are streamed as is to the daemon, and
+	// closed early when it disconnects.
+	Response string
+	// NoError is set for driver methods that can't fail.
+	NoError bool
+	// ErrorField is the name of the string field of Response carrying the
+	// error, for protocols where drivers report errors in the response
+	// rather than by returning them.
+	ErrorField string
+	// NilResponse is the error reported when the driver returns a nil
+	// Response without an error. Nil responses are sent as is otherwise.
+	NilResponse string
+	// RawRequest is set for routes taking a raw body rather than a JSON
+	// one. The request is then read from the http request by its
+	// decodeHTTP method, and sent by the client as the query parameters and
+	// body returned by its encodeHTTP method:
+	//
+	//	func (req *T) decodeHTTP(r *http.Request) error
+	//	func (req *T) encodeHTTP() (url.Values, io.Reader)
+	RawRequest bool
+	// Query are the names of the query parameters of a RawRequest, for the
+	// schemas of the protocol.
+	Query []string
+	// Description describes the content of the body of a RawRequest, or of
+	// a streamed response, for the schemas of the protocol.
+	Description string
+}
+
+// Streams reports whether any route of p streams its response.
+func (p *Protocol) Streams() bool {
+	for _, r := range p.Routes {
+		if r.Stream() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Protocol) validate() error {
+	if p.Interface == "" || p.Field == "" {
+		return fmt.Errorf("Interface and Field are required")
+	}
+	if len(p.Routes) == 0 {
+		return fmt.Errorf("no routes")
+	}
+	seen := make(map[string]bool)
+	for _, r := range p.Routes {
+		if r.Method == "" || r.Path == "" {
+			return fmt.Errorf("Method and Path are required for every route")
+		}
+		if seen[r.Method] {
+			return fmt.Errorf("duplicate method %s", r.Method)
+		}
+		seen[r.Method] = true
+		if r.NoError && r.ErrorField != "" {
+			return fmt.Errorf("%s: NoError and ErrorField are exclusive", r.Method)
+		}
+		if (r.NoError || r.ErrorField != "") && r.Response == "" {
+			return fmt.Errorf("%s: a Response is required without an error result", r.Method)
+		}
+		if r.ErrorField != "" && r.ResponsePointer() {
+			return fmt.Errorf("%s: ErrorField requires a value Response", r.Method)
+		}
+		if r.Stream() && !r.ReturnsError() {
+			return fmt.Errorf("%s: streamed responses require an error result", r.Method)
+		}
+		if r.RawRequest && !r.RequestPointer() {
+			return fmt.Errorf("%s: RawRequest requires a pointer Request", r.Method)
+		}
+		if len(r.Query) > 0 && !r.RawRequest {
+			return fmt.Errorf("%s: Query requires RawRequest", r.Method)
+		}
+		if r.Description != "" && !r.RawRequest && !r.Stream() {
+			return fmt.Errorf("%s: Description requires RawRequest or a streamed Response", r.Method)
+		}
+		if r.NilResponse != "" && !r.ResponsePointer() {
+			return fmt.Errorf("%s: NilResponse requires a pointer Response", r.Method)
+		}
+	}
+	return nil
+}
+
+// ReturnsError reports whether the driver method has an error result.
+func (r *Route) ReturnsError() bool {
+	return !r.NoError && r.ErrorField == ""
+}
+
+// Stream reports whether the response is streamed to the daemon.
+func (r *Route) Stream() bool {
+	return r.Response == streamType
+}
+
+// RequestPointer reports whether the driver takes a pointer to the request.
+func (r *Route) RequestPointer() bool {
+	return strings.HasPrefix(r.Request, "*")
+}
+
+// RequestType is the request type without pointer indirection.
+func (r *Route) RequestType() string {
+	return strings.TrimPrefix(r.Request, "*")
+}
+
+// ResponsePointer reports whether the driver returns a pointer to the response.
+func (r *Route) ResponsePointer() bool {
+	return strings.HasPrefix(r.Response, "*")
+}
+
+// ResponseType is the response type without pointer indirection.
+func (r *Route) ResponseType() string {
+	return strings.TrimPrefix(r.Response, "*")
+}
+
+// Signature is the parameter and result list of the driver method, naming
+// the request parameter param when it is not empty.
+func (r *Route) Signature(param string) string {
+	var results []string
+	if r.Response != "" {
+		results = append(results, r.Response)
+	}
+	if r.ReturnsError() {
+		results = append(results, "error")
+	}
+	s := "(" + r.Request + ")"
+	if param != "" && r.Request != "" {
+		s = "(" + param + " " + r.Request + ")"
+	}
+	switch len(results) {
+	case 0:
+	case 1:
+		s += " " + results[0]
+	default:
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	return s
+}
+
+// ZeroRequest is an expression building an empty request.
+func (r *Route) ZeroRequest() string {
+	if r.RequestPointer() {
+		return "&" + r.RequestType() + "{}"
+	}
+	return r.Request + "{}"
+}
+
+// ZeroResponse is an expression building an empty response.
+func (r *Route) ZeroResponse() string {
+	if r.ResponsePointer() {
+		return "&" + r.ResponseType() + "{}"
+	}
+	return r.Response + "{}"
+}
+
+// NilResponseValue is the response returned along an error.
+func (r *Route) NilResponseValue() string {
+	if r.ResponsePointer() || r.Stream() {
+		return "nil"
+	}
+	return r.Response + "{}"
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const modulePath = "github.com/docker/go-plugins-helpers"
+
+// registryPackage is a package implementing a protocol, listed in the
+// registry of cmd/protocolschema.
+type registryPackage struct {
+	*Protocol
+	// Dir is the directory of the package, at the root of the module.
+	Dir string
+	// Name is the name of the protocol in the plugin manifest.
+	Name string
+	// Paths are the values of the path constants of the routes.
+	Paths map[string]string
+}
+
+// runRegistry writes the protocols var of cmd/protocolschema, listing the
+// routes of the packages in dirs, to registry_gen.go in out.
+func runRegistry(dirs []string, out string) error {
+	if len(dirs) == 0 {
+		return fmt.Errorf("no package directories")
+	}
+	var pkgs []*registryPackage
+	for _, dir := range dirs {
+		p, err := loadRegistryPackage(dir)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, p)
+	}
+	src, err := format.Source([]byte(generateRegistry(pkgs)))
+	if err != nil {
+		return fmt.Errorf("registry_gen.go: %v", err)
+	}
+	return os.WriteFile(filepath.Join(out, "registry_gen.go"), src, 0644)
+}
+
+// loadRegistryPackage reads the protocol description of the package in dir,
+// and resolves its manifest and path constants.
+func loadRegistryPackage(dir string) (*registryPackage, error) {
+	fset := token.NewFileSet()
+	parsed, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) != 1 {
+		return nil, fmt.Errorf("%s: expected a single package, got %d", dir, len(parsed))
+	}
+	var pkg *ast.Package
+	for _, pkg = range parsed {
+	}
+	p, err := readProtocol(filepath.Join(dir, "protocol.json"), pkg.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	consts := make(map[string]ast.Expr)
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, id := range vs.Names {
+					if i < len(vs.Values) {
+						consts[id.Name] = vs.Values[i]
+					}
+				}
+			}
+		}
+	}
+	manifest, err := constString(consts, &ast.Ident{Name: "manifest"})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	var m struct{ Implements []string }
+	if err := json.Unmarshal([]byte(manifest), &m); err != nil || len(m.Implements) != 1 {
+		return nil, fmt.Errorf("%s: the manifest must implement a single protocol", dir)
+	}
+
+	rp := &registryPackage{Protocol: p, Dir: filepath.Base(dir), Name: m.Implements[0], Paths: make(map[string]string)}
+	for _, r := range p.Routes {
+		if rp.Paths[r.Path], err = constString(consts, &ast.Ident{Name: r.Path}); err != nil {
+			return nil, fmt.Errorf("%s: %v", dir, err)
+		}
+	}
+	return rp, nil
+}
+
+// constString evaluates the constant expression e, made of string literals,
+// the constants of consts and their concatenations.
+func constString(consts map[string]ast.Expr, e ast.Expr) (string, error) {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return strconv.Unquote(e.Value)
+		}
+	case *ast.Ident:
+		v, ok := consts[e.Name]
+		if !ok {
+			return "", fmt.Errorf("constant %s not found", e.Name)
+		}
+		return constString(consts, v)
+	case *ast.ParenExpr:
+		return constString(consts, e.X)
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			break
+		}
+		x, err := constString(consts, e.X)
+		if err != nil {
+			return "", err
+		}
+		y, err := constString(consts, e.Y)
+		return x + y, err
+	}
+	return "", fmt.Errorf("unsupported constant expression %T", e)
+}
+
+func generateRegistry(pkgs []*registryPackage) string {
+	var paths []string
+	for _, p := range pkgs {
+		for _, r := range p.Routes {
+			if r.Request != "" || (r.Response != "" && !r.Stream()) {
+				paths = append(paths, modulePath+"/"+p.Dir)
+				break
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(header)
+	b.WriteString("package main\n\n")
+	b.WriteString(imports(paths...))
+	b.WriteString("// protocols are the protocols exported, their routes follow the order of\n// the protocol.json file of their package.\n")
+	b.WriteString("var protocols = []Protocol{\n")
+	for _, p := range pkgs {
+		fmt.Fprintf(&b, "\t{\n\t\tName: %q,\n\t\tPackage: %q,\n\t\tRoutes: []Route{\n", p.Name, p.Dir)
+		for _, r := range p.Routes {
+			req := "nil"
+			if r.Request != "" {
+				req = p.Package + "." + r.RequestType() + "{}"
+			}
+			if r.RawRequest {
+				req = fmt.Sprintf("rawRequest{%s, %#v, %q}", req, r.Query, r.Description)
+			}
+			res := "nil"
+			switch {
+			case r.Stream():
+				res = fmt.Sprintf("byteStream{%q}", r.Description)
+			case r.Response != "":
+				res = p.Package + "." + r.ResponseType() + "{}"
+			}
+			fmt.Fprintf(&b, "\t\t\t{%q, %s, %s},\n", p.Paths[r.Path], req, res)
+		}
+		b.WriteString("\t\t},\n\t},\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	activatePath      = "/Plugin.Activate"
+)
+
+// manifest is the response of the activation method of every plugin.
+type manifest struct {
+	Implements []string
+}
+
+func newReflector(prefix string) *Reflector {
+	r := NewReflector(prefix)
+	for t, s := range customSchemas {
+		r.Custom[t] = s
+	}
+	return r
+}
+
+// routeName is the name of the definitions aliasing the bodies of a route.
+func routeName(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
+
+// JSONSchema builds a JSON Schema document defining the request and response
+// bodies of every route of p, as "<Route>.Request" and "<Route>.Response".
+func JSONSchema(p Protocol) map[string]interface{} {
+	r := newReflector("#/$defs/")
+	for _, route := range p.Routes {
+		name := routeName(route.Path)
+		if route.Request != nil {
+			r.Definitions[name+".Request"] = requestSchema(r, route.Request)
+		}
+		r.Definitions[name+".Response"] = responseSchema(r, route.Response)
+	}
+	r.Definitions["ErrorResponse"] = r.Reflect(reflect.TypeOf(sdk.ErrorResponse{}))
+	return map[string]interface{}{
+		"$schema": jsonSchemaDialect,
+		"title":   p.Name + " plugin protocol",
+		"$defs":   r.Definitions,
+	}
+}
+
+func requestSchema(r *Reflector, req interface{}) *Schema {
+	if raw, ok := req.(rawRequest); ok {
+		return &Schema{Type: "string", Format: "binary", Description: raw.Description}
+	}
+	return r.Reflect(reflect.TypeOf(req))
+}
+
+func responseSchema(r *Reflector, res interface{}) *Schema {
+	if res == nil {
+		return &Schema{Type: "object"}
+	}
+	if s, ok := res.(byteStream); ok {
+		return &Schema{Type: "string", Format: "binary", Description: s.Description}
+	}
+	return r.Reflect(reflect.TypeOf(res))
+}
+
+type openAPIDocument struct {
+	OpenAPI           string                                  `json:"openapi"`
+	JSONSchemaDialect string                                  `json:"jsonSchemaDialect"`
+	Info              openAPIInfo                             `json:"info"`
+	Paths             map[string]map[string]*openAPIOperation `json:"paths"`
+	Components        openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name   string  `json:"name"`
+	In     string  `json:"in"`
+	Schema *Schema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+func jsonContent(s *Schema) map[string]*openAPIMediaType {
+	return map[string]*openAPIMediaType{sdk.DefaultContentTypeV1_1: {Schema: s}}
+}
+
+// OpenAPI builds an OpenAPI 3.1 document describing the routes of protocols.
+// Every route is a POST operation tagged with the name of its protocol.
+func OpenAPI(protocols []Protocol) *openAPIDocument {
+	r := newReflector("#/components/schemas/")
+	errorResponse := &openAPIResponse{
+		Description: "Error reported by the plugin",
+		Content:     jsonContent(r.Reflect(reflect.TypeOf(sdk.ErrorResponse{}))),
+	}
+
+	doc := &openAPIDocument{
+		OpenAPI:           "3.1.0",
+		JSONSchemaDialect: jsonSchemaDialect,
+		Info: openAPIInfo{
+			Title:       "Docker plugin protocols",
+			Description: "Methods the docker daemon calls on plugins. All of them are POST requests.",
+			Version:     "1.1",
+		},
+		Paths: map[string]map[string]*openAPIOperation{
+			activatePath: {"post": {
+				OperationID: routeName(activatePath),
+				Tags:        []string{"Plugin"},
+				Responses: map[string]*openAPIResponse{
+					"200": {Description: "Plugin manifest", Content: jsonContent(r.Reflect(reflect.TypeOf(manifest{})))},
+				},
+			}},
+		},
+		Components: openAPIComponents{Schemas: r.Definitions},
+	}
+
+	for _, p := range protocols {
+		for _, route := range p.Routes {
+			op := &openAPIOperation{
+				OperationID: routeName(route.Path),
+				Tags:        []string{p.Name},
+				Responses: map[string]*openAPIResponse{
+					"200":     {Description: "Success", Content: jsonContent(responseSchema(r, route.Response))},
+					"default": errorResponse,
+				},
+			}
+			if route.Request != nil {
+				op.RequestBody = &openAPIBody{Required: true, Content: jsonContent(requestSchema(r, route.Request))}
+			}
+			if raw, ok := route.Request.(rawRequest); ok {
+				for _, q := range raw.Query {
+					op.Parameters = append(op.Parameters, &openAPIParameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+				}
+			}
+			doc.Paths[route.Path] = map[string]*openAPIOperation{"post": op}
+		}
+	}
+	return doc
+}
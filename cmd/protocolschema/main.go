@@ -0,0 +1,47 @@
+// Command protocolschema exports the request and response bodies of the
+// plugin protocols implemented in this repository as JSON Schema documents,
+// one per protocol, and as a single OpenAPI document.
+//
+// The schemas are built from the Go types and their json tags, so they
+// describe what the handlers of this repository accept and send.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	out := flag.String("out", ".", "directory to write the documents to")
+	flag.Parse()
+
+	if err := run(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "protocolschema: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(out string) error {
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	for _, p := range protocols {
+		name := strings.ToLower(p.Name) + ".schema.json"
+		if err := writeJSON(filepath.Join(out, name), JSONSchema(p)); err != nil {
+			return err
+		}
+	}
+	return writeJSON(filepath.Join(out, "openapi.json"), OpenAPI(protocols))
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProtocolsMatchDescriptions checks the routes of registry_gen.go
+// against the protocol.json files the handlers are generated from.
+func TestProtocolsMatchDescriptions(t *testing.T) {
+	for _, p := range protocols {
+		b, err := os.ReadFile(filepath.Join("..", "..", p.Package, "protocol.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var desc struct {
+			Routes []struct {
+				Method   string
+				Request  string
+				Response string
+			}
+		}
+		if err := json.Unmarshal(b, &desc); err != nil {
+			t.Fatalf("%s: %v", p.Package, err)
+		}
+		if len(desc.Routes) != len(p.Routes) {
+			t.Fatalf("%s: expected %d routes, got %d", p.Name, len(desc.Routes), len(p.Routes))
+		}
+		for i, d := range desc.Routes {
+			r := p.Routes[i]
+			if got := typeName(r.Request); got != strings.TrimPrefix(d.Request, "*") {
+				t.Fatalf("%s: expected request %q for %s, got %q", p.Name, d.Request, d.Method, got)
+			}
+			if got := typeName(r.Response); got != strings.TrimPrefix(d.Response, "*") {
+				t.Fatalf("%s: expected response %q for %s, got %q", p.Name, d.Response, d.Method, got)
+			}
+		}
+	}
+}
+
+func typeName(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if _, ok := v.(byteStream); ok {
+		return "io.ReadCloser"
+	}
+	if r, ok := v.(rawRequest); ok {
+		return typeName(r.Type)
+	}
+	return reflect.TypeOf(v).Name()
+}
+
+func TestJSONSchema(t *testing.T) {
+	b, err := json.Marshal(JSONSchema(protocols[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Defs map[string]struct {
+			Ref        string `json:"$ref"`
+			Properties map[string]json.RawMessage
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if ref := doc.Defs["VolumeDriver.Create.Request"].Ref; ref != "#/$defs/volume.CreateRequest" {
+		t.Fatalf("expected create request to reference volume.CreateRequest, got %q", ref)
+	}
+	props := doc.Defs["volume.CreateRequest"].Properties
+	if _, ok := props["Opts"]; !ok {
+		t.Fatalf("expected Opts property, got %v", props)
+	}
+	if _, ok := props["Unknown"]; ok {
+		t.Fatal("unexpected Unknown property")
+	}
+	if string(props["Opts"]) != `{"additionalProperties":{"type":"string"},"type":["object","null"]}` {
+		t.Fatalf("unexpected Opts schema %s", props["Opts"])
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := OpenAPI(protocols)
+	for _, p := range protocols {
+		for _, r := range p.Routes {
+			op, ok := doc.Paths[r.Path]["post"]
+			if !ok {
+				t.Fatalf("missing operation for %s", r.Path)
+			}
+			if (op.RequestBody != nil) != (r.Request != nil) {
+				t.Fatalf("%s: unexpected request body %v", r.Path, op.RequestBody)
+			}
+		}
+	}
+	applyDiff := doc.Paths["/GraphDriver.ApplyDiff"]["post"]
+	if len(applyDiff.Parameters) != 2 || applyDiff.Parameters[0].Name != "id" {
+		t.Fatalf("expected id and parent query parameters, got %+v", applyDiff.Parameters)
+	}
+	cert := doc.Components.Schemas["authorization.Request"].Properties["RequestPeerCertificates"]
+	if cert.Items == nil || cert.Items.Type != "string" {
+		t.Fatalf("expected peer certificates to be strings, got %+v", cert.Items)
+	}
+}
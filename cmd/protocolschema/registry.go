@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/docker/go-plugins-helpers/authorization"
+)
+
+//go:generate go run ../protocolgen -registry ../../volume ../../network ../../ipam ../../graphdriver ../../logging ../../metrics ../../authorization ../../secrets
+
+// Protocol lists the routes of a plugin protocol.
+type Protocol struct {
+	// Name is the name of the protocol in the plugin manifest.
+	Name string
+	// Package is the directory of the package implementing the protocol.
+	Package string
+	Routes  []Route
+}
+
+// Route is a method of a plugin protocol, with zero values of the types its
+// bodies are encoded from.
+type Route struct {
+	// Path is the http path of the method.
+	Path string
+	// Request is nil for methods without a request body, and a rawRequest
+	// for methods taking a raw body.
+	Request interface{}
+	// Response is nil for methods replying with an empty object, and
+	// byteStream for methods streaming their response.
+	Response interface{}
+}
+
+// rawRequest stands for requests sent as a raw body, along query
+// parameters.
+type rawRequest struct {
+	// Type is the request type of the driver method.
+	Type interface{}
+	// Query are the names of the query parameters.
+	Query []string
+	// Description describes the content of the body.
+	Description string
+}
+
+// byteStream stands for responses streamed as is rather than encoded.
+type byteStream struct {
+	// Description describes the content of the stream.
+	Description string
+}
+
+// customSchemas are the schemas of the protocol types with their own JSON
+// encoding.
+var customSchemas = map[reflect.Type]*Schema{
+	reflect.TypeOf(authorization.PeerCertificate{}): {
+		Type:            "string",
+		ContentEncoding: "base64",
+		Description:     "PEM encoded X.509 certificate",
+	},
+}
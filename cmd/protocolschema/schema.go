@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema, as used by JSON Schema 2020-12 and OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Nullable             bool               `json:"-"`
+}
+
+// MarshalJSON encodes nullable schemas with a type union, the way JSON
+// Schema 2020-12 expresses them.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type schema Schema
+	if !s.Nullable || s.Type == "" {
+		return json.Marshal((*schema)(s))
+	}
+	b, err := json.Marshal((*schema)(s))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	m["type"] = []string{s.Type, "null"}
+	return json.Marshal(m)
+}
+
+// Reflector builds schemas from Go types, following the rules of
+// encoding/json. Named structures are added to Definitions and referenced
+// with RefPrefix.
+type Reflector struct {
+	RefPrefix   string
+	Definitions map[string]*Schema
+	// Custom holds the schemas of types with their own JSON encoding.
+	Custom map[reflect.Type]*Schema
+}
+
+// NewReflector creates a Reflector referencing definitions under prefix.
+func NewReflector(prefix string) *Reflector {
+	return &Reflector{
+		RefPrefix:   prefix,
+		Definitions: make(map[string]*Schema),
+		Custom:      make(map[reflect.Type]*Schema),
+	}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// definitionName is the name of t in Definitions, qualified by its package
+// name since several protocols declare types with the same name.
+func definitionName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
+
+// Reflect returns the schema of values of type t.
+func (r *Reflector) Reflect(t reflect.Type) *Schema {
+	if s, ok := r.Custom[t]; ok {
+		return s
+	}
+	switch {
+	case t.Kind() == reflect.Ptr:
+		s := *r.Reflect(t.Elem())
+		s.Nullable = true
+		return &s
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(marshalerType), reflect.PtrTo(t).Implements(marshalerType):
+		// unknown custom encoding, accept anything
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := 0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: r.Reflect(t.Elem()), Nullable: true}
+	case reflect.Array:
+		n := t.Len()
+		return &Schema{Type: "array", Items: r.Reflect(t.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.Reflect(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.reflectStruct(t)
+		}
+		name := definitionName(t)
+		if _, ok := r.Definitions[name]; !ok {
+			// register first, structures may be recursive
+			r.Definitions[name] = &Schema{}
+			*r.Definitions[name] = *r.reflectStruct(t)
+		}
+		return &Schema{Ref: r.RefPrefix + name}
+	default:
+		panic(fmt.Sprintf("protocolschema: unsupported type %s", t))
+	}
+}
+
+func (r *Reflector) reflectStruct(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(s, t)
+	return s
+}
+
+// addFields adds the properties encoded for the fields of t to s, including
+// the ones promoted from embedded structures. As with encoding/json, fields
+// of the outer structure take precedence over promoted ones.
+func (r *Reflector) addFields(s *Schema, t reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := r.Reflect(ft)
+		if strings.Contains(opts, "string") {
+			fs = &Schema{Type: "string"}
+		}
+		s.Properties[name] = fs
+	}
+	for _, et := range embedded {
+		promoted := &Schema{Properties: make(map[string]*Schema)}
+		r.addFields(promoted, et)
+		for name, fs := range promoted.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = fs
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package graphdriver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+//go:generate go run ../cmd/protocolgen
+
+const (
+	manifest            = `{"Implements": ["GraphDriver"]}`
+	initPath            = "/GraphDriver.Init"
+	createPath          = "/GraphDriver.Create"
+	createReadWritePath = "/GraphDriver.CreateReadWrite"
+	removePath          = "/GraphDriver.Remove"
+	getPath             = "/GraphDriver.Get"
+	putPath             = "/GraphDriver.Put"
+	existsPath          = "/GraphDriver.Exists"
+	statusPath          = "/GraphDriver.Status"
+	getMetadataPath     = "/GraphDriver.GetMetadata"
+	cleanupPath         = "/GraphDriver.Cleanup"
+	diffPath            = "/GraphDriver.Diff"
+	changesPath         = "/GraphDriver.Changes"
+	applyDiffPath       = "/GraphDriver.ApplyDiff"
+	diffSizePath        = "/GraphDriver.DiffSize"
+	capabilitiesPath    = "/GraphDriver.Capabilities"
+)
+
+// IDMap maps a range of user or group IDs of containers to the host.
+type IDMap struct {
+	ContainerID int `json:"container_id"`
+	HostID      int `json:"host_id"`
+	Size        int `json:"size"`
+}
+
+// InitRequest structure for a graph driver init request
+type InitRequest struct {
+	Home    string
+	Opts    []string
+	UIDMaps []IDMap
+	GIDMaps []IDMap
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// CreateRequest structure for a layer create request, also used for read
+// write layers
+type CreateRequest struct {
+	ID         string
+	Parent     string
+	MountLabel string
+	StorageOpt map[string]string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// RemoveRequest structure for a layer remove request
+type RemoveRequest struct {
+	ID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// GetRequest structure for a layer get request
+type GetRequest struct {
+	ID         string
+	MountLabel string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// GetResponse structure for a layer get response
+type GetResponse struct {
+	// Dir is the path the layer is mounted at.
+	Dir string
+}
+
+// PutRequest structure for a layer put request
+type PutRequest struct {
+	ID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// ExistsRequest structure for a layer exists request
+type ExistsRequest struct {
+	ID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// ExistsResponse structure for a layer exists response
+type ExistsResponse struct {
+	Exists bool
+}
+
+// StatusResponse structure for a graph driver status response
+type StatusResponse struct {
+	// Status are the key and value pairs listed by docker info.
+	Status [][2]string
+}
+
+// GetMetadataRequest structure for a layer metadata request
+type GetMetadataRequest struct {
+	ID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// GetMetadataResponse structure for a layer metadata response
+type GetMetadataResponse struct {
+	Metadata map[string]string
+}
+
+// DiffRequest structure for a layer diff request, also used for changes and
+// diff size requests
+type DiffRequest struct {
+	ID     string
+	Parent string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// ChangesResponse structure for a layer changes response
+type ChangesResponse struct {
+	Changes []Change
+}
+
+// ChangeKind is the kind of a change to a file of a layer.
+type ChangeKind int
+
+const (
+	// ChangeModify is a modified file.
+	ChangeModify ChangeKind = iota
+	// ChangeAdd is an added file.
+	ChangeAdd
+	// ChangeDelete is a deleted file.
+	ChangeDelete
+)
+
+// Change represents a change to a file of a layer.
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
+
+// ApplyDiffRequest structure for a layer apply diff request. Its fields are
+// sent as query parameters and Diff as the body of the request.
+type ApplyDiffRequest struct {
+	ID     string
+	Parent string
+	// Diff is the tar archive of the diff. The driver must read it before
+	// replying.
+	Diff io.Reader
+}
+
+func (req *ApplyDiffRequest) decodeHTTP(r *http.Request) error {
+	q := r.URL.Query()
+	req.ID, req.Parent, req.Diff = q.Get("id"), q.Get("parent"), r.Body
+	return nil
+}
+
+func (req *ApplyDiffRequest) encodeHTTP() (url.Values, io.Reader) {
+	return url.Values{"id": {req.ID}, "parent": {req.Parent}}, req.Diff
+}
+
+// ApplyDiffResponse structure for a layer apply diff response
+type ApplyDiffResponse struct {
+	// Size is the size of the applied diff, in bytes.
+	Size int64
+}
+
+// DiffSizeResponse structure for a layer diff size response
+type DiffSizeResponse struct {
+	Size int64
+}
+
+// CapabilitiesResponse structure for a graph driver capabilities response
+type CapabilitiesResponse struct {
+	Capabilities Capabilities
+}
+
+// Capabilities represents the list of capabilities a graph driver can return
+type Capabilities struct {
+	// ReproducesExactDiffs is set by drivers whose diffs of a layer are
+	// identical to the diffs applied to create it.
+	ReproducesExactDiffs bool
+}
+
+// Handler forwards requests and responses between the docker daemon and the plugin.
+type Handler struct {
+	driver Driver
+	sdk.Handler
+}
+
+// NewHandler initializes the request handler with a driver implementation.
+func NewHandler(driver Driver) *Handler {
+	h := &Handler{driver, sdk.NewHandler(manifest)}
+	h.initMux()
+	return h
+}
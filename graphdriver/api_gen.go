@@ -0,0 +1,197 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package graphdriver
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Driver represent the interface a graph driver must fulfill.
+type Driver interface {
+	Init(*InitRequest) error
+	Create(*CreateRequest) error
+	CreateReadWrite(*CreateRequest) error
+	Remove(*RemoveRequest) error
+	Get(*GetRequest) (*GetResponse, error)
+	Put(*PutRequest) error
+	Exists(*ExistsRequest) (*ExistsResponse, error)
+	Status() (*StatusResponse, error)
+	GetMetadata(*GetMetadataRequest) (*GetMetadataResponse, error)
+	Cleanup() error
+	// Diff returns the tar archive of the changes of a layer to its parent.
+	Diff(*DiffRequest) (io.ReadCloser, error)
+	Changes(*DiffRequest) (*ChangesResponse, error)
+	// ApplyDiff extracts the tar archive of a diff into a layer.
+	ApplyDiff(*ApplyDiffRequest) (*ApplyDiffResponse, error)
+	DiffSize(*DiffRequest) (*DiffSizeResponse, error)
+	Capabilities() (*CapabilitiesResponse, error)
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(initPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &InitRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.Init(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(createPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &CreateRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.Create(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(createReadWritePath, func(w http.ResponseWriter, r *http.Request) {
+		req := &CreateRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.CreateReadWrite(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(removePath, func(w http.ResponseWriter, r *http.Request) {
+		req := &RemoveRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.Remove(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(getPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &GetRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.Get(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(putPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &PutRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.Put(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(existsPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ExistsRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.Exists(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(statusPath, func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.driver.Status()
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(getMetadataPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &GetMetadataRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.GetMetadata(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(cleanupPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := h.driver.Cleanup(); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(diffPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DiffRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.Diff(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.StreamResponseContext(r.Context(), w, res)
+	})
+	h.HandleFunc(changesPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DiffRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.Changes(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(applyDiffPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ApplyDiffRequest{}
+		if err := req.decodeHTTP(r); err != nil {
+			sdk.EncodeError(w, sdk.InvalidArgument(err))
+			return
+		}
+		res, err := h.driver.ApplyDiff(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(diffSizePath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DiffRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.DiffSize(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.driver.Capabilities()
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+}
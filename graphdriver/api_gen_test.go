@@ -0,0 +1,196 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package graphdriver
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// protocolStub records the calls it receives and fails all of them when
+// fail is set.
+type protocolStub struct {
+	calls map[string]int
+	fail  bool
+}
+
+func (s *protocolStub) result(method string) error {
+	s.calls[method]++
+	if s.fail {
+		return sdk.NotFound(errors.New(method + " failed"))
+	}
+	return nil
+}
+
+func (s *protocolStub) Init(req *InitRequest) error {
+	return s.result("Init")
+}
+
+func (s *protocolStub) Create(req *CreateRequest) error {
+	return s.result("Create")
+}
+
+func (s *protocolStub) CreateReadWrite(req *CreateRequest) error {
+	return s.result("CreateReadWrite")
+}
+
+func (s *protocolStub) Remove(req *RemoveRequest) error {
+	return s.result("Remove")
+}
+
+func (s *protocolStub) Get(req *GetRequest) (*GetResponse, error) {
+	if err := s.result("Get"); err != nil {
+		return nil, err
+	}
+	return &GetResponse{}, nil
+}
+
+func (s *protocolStub) Put(req *PutRequest) error {
+	return s.result("Put")
+}
+
+func (s *protocolStub) Exists(req *ExistsRequest) (*ExistsResponse, error) {
+	if err := s.result("Exists"); err != nil {
+		return nil, err
+	}
+	return &ExistsResponse{}, nil
+}
+
+func (s *protocolStub) Status() (*StatusResponse, error) {
+	if err := s.result("Status"); err != nil {
+		return nil, err
+	}
+	return &StatusResponse{}, nil
+}
+
+func (s *protocolStub) GetMetadata(req *GetMetadataRequest) (*GetMetadataResponse, error) {
+	if err := s.result("GetMetadata"); err != nil {
+		return nil, err
+	}
+	return &GetMetadataResponse{}, nil
+}
+
+func (s *protocolStub) Cleanup() error {
+	return s.result("Cleanup")
+}
+
+func (s *protocolStub) Diff(req *DiffRequest) (io.ReadCloser, error) {
+	if err := s.result("Diff"); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("Diff")), nil
+}
+
+func (s *protocolStub) Changes(req *DiffRequest) (*ChangesResponse, error) {
+	if err := s.result("Changes"); err != nil {
+		return nil, err
+	}
+	return &ChangesResponse{}, nil
+}
+
+func (s *protocolStub) ApplyDiff(req *ApplyDiffRequest) (*ApplyDiffResponse, error) {
+	if err := s.result("ApplyDiff"); err != nil {
+		return nil, err
+	}
+	return &ApplyDiffResponse{}, nil
+}
+
+func (s *protocolStub) DiffSize(req *DiffRequest) (*DiffSizeResponse, error) {
+	if err := s.result("DiffSize"); err != nil {
+		return nil, err
+	}
+	return &DiffSizeResponse{}, nil
+}
+
+func (s *protocolStub) Capabilities() (*CapabilitiesResponse, error) {
+	if err := s.result("Capabilities"); err != nil {
+		return nil, err
+	}
+	return &CapabilitiesResponse{}, nil
+}
+
+func checkProtocolError(t *testing.T, method string, fail bool, err error) {
+	t.Helper()
+	switch {
+	case !fail && err != nil:
+		t.Fatalf("%s: unexpected error: %v", method, err)
+	case fail && !sdk.IsNotFound(err):
+		t.Fatalf("%s: expected a not found error, got %v", method, err)
+	case fail && err.Error() != method+" failed":
+		t.Fatalf("%s: expected error %q, got %q", method, method+" failed", err.Error())
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	s := &protocolStub{calls: make(map[string]int)}
+	h := &Handler{driver: s, Handler: sdk.NewHandler(manifest)}
+	h.initMux()
+	l := sockets.NewInmemSocket("protocol", 0)
+	go h.Serve(l)
+	defer l.Close()
+
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	for _, fail := range []bool{false, true} {
+		s.fail = fail
+		checkProtocolError(t, "Init", fail, c.Init(&InitRequest{}))
+		checkProtocolError(t, "Create", fail, c.Create(&CreateRequest{}))
+		checkProtocolError(t, "CreateReadWrite", fail, c.CreateReadWrite(&CreateRequest{}))
+		checkProtocolError(t, "Remove", fail, c.Remove(&RemoveRequest{}))
+		_, errGet := c.Get(&GetRequest{})
+		checkProtocolError(t, "Get", fail, errGet)
+		checkProtocolError(t, "Put", fail, c.Put(&PutRequest{}))
+		_, errExists := c.Exists(&ExistsRequest{})
+		checkProtocolError(t, "Exists", fail, errExists)
+		_, errStatus := c.Status()
+		checkProtocolError(t, "Status", fail, errStatus)
+		_, errGetMetadata := c.GetMetadata(&GetMetadataRequest{})
+		checkProtocolError(t, "GetMetadata", fail, errGetMetadata)
+		checkProtocolError(t, "Cleanup", fail, c.Cleanup())
+		rcDiff, errDiff := c.Diff(&DiffRequest{})
+		checkProtocolError(t, "Diff", fail, errDiff)
+		if !fail {
+			b, err := io.ReadAll(rcDiff)
+			rcDiff.Close()
+			if err != nil || string(b) != "Diff" {
+				t.Fatalf("Diff: unexpected stream %q: %v", b, err)
+			}
+		}
+		_, errChanges := c.Changes(&DiffRequest{})
+		checkProtocolError(t, "Changes", fail, errChanges)
+		_, errApplyDiff := c.ApplyDiff(&ApplyDiffRequest{})
+		checkProtocolError(t, "ApplyDiff", fail, errApplyDiff)
+		_, errDiffSize := c.DiffSize(&DiffRequest{})
+		checkProtocolError(t, "DiffSize", fail, errDiffSize)
+		_, errCapabilities := c.Capabilities()
+		checkProtocolError(t, "Capabilities", fail, errCapabilities)
+	}
+
+	for _, m := range []string{
+		"Init",
+		"Create",
+		"CreateReadWrite",
+		"Remove",
+		"Get",
+		"Put",
+		"Exists",
+		"Status",
+		"GetMetadata",
+		"Cleanup",
+		"Diff",
+		"Changes",
+		"ApplyDiff",
+		"DiffSize",
+		"Capabilities",
+	} {
+		if s.calls[m] != 2 {
+			t.Fatalf("expected 2 calls to %s, got %d", m, s.calls[m])
+		}
+	}
+}
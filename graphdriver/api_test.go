@@ -0,0 +1,394 @@
+package graphdriver
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// testDriver keeps the content of a single file per layer. It only
+// implements the diff methods.
+type testDriver struct {
+	Driver
+	layers map[string][]byte
+}
+
+func (d *testDriver) Diff(req *DiffRequest) (io.ReadCloser, error) {
+	content, ok := d.layers[req.ID]
+	if !ok {
+		return nil, sdk.NotFound(errors.New("no such layer"))
+	}
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	tw.WriteHeader(&tar.Header{Name: "file", Mode: 0644, Size: int64(len(content))})
+	tw.Write(content)
+	tw.Close()
+	return io.NopCloser(&b), nil
+}
+
+func (d *testDriver) ApplyDiff(req *ApplyDiffRequest) (*ApplyDiffResponse, error) {
+	if req.Parent != "base" {
+		return nil, sdk.InvalidArgument(errors.New("unexpected parent " + req.Parent))
+	}
+	tr := tar.NewReader(req.Diff)
+	if _, err := tr.Next(); err != nil {
+		return nil, sdk.InvalidArgument(err)
+	}
+	content, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, err
+	}
+	d.layers[req.ID] = content
+	return &ApplyDiffResponse{Size: int64(len(content))}, nil
+}
+
+func TestDiffs(t *testing.T) {
+	d := &testDriver{layers: map[string][]byte{"l1": []byte("hello")}}
+	h := NewHandler(d)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	rc, err := c.Diff(&DiffRequest{ID: "l1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Diff(&DiffRequest{ID: "l0"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found diffing unknown layer, got %v", err)
+	}
+
+	// the daemon sends the archive as the body, the layer in the query
+	client := &http.Client{Transport: &http.Transport{Dial: l.Dial}}
+	resp, err := client.Post("http://plugin"+applyDiffPath+"?id=l2&parent=base", sdk.DefaultContentTypeV1_1, bytes.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var res ApplyDiffResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || res.Size != 5 || string(d.layers["l2"]) != "hello" {
+		t.Fatalf("unexpected apply diff result %d %+v: %q", resp.StatusCode, res, d.layers["l2"])
+	}
+
+	// and so does the client
+	if _, err := c.ApplyDiff(&ApplyDiffRequest{ID: "l3", Parent: "base", Diff: bytes.NewReader(diff)}); err != nil {
+		t.Fatal(err)
+	}
+	if string(d.layers["l3"]) != "hello" {
+		t.Fatalf("expected l3 to hold hello, got %q", d.layers["l3"])
+	}
+	if _, err := c.ApplyDiff(&ApplyDiffRequest{ID: "l4", Parent: "other", Diff: bytes.NewReader(diff)}); !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+}
+
+// writeTree creates the files of tree under dir, directories ending with a
+// slash, with the same modification time.
+func writeTree(t *testing.T, dir string, tree map[string]string) {
+	mtime := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	for name, content := range tree {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var baseTree = map[string]string{"a": "a", "dir/b": "b", "gone": "gone", "gonedir/x": "x", "keep/": ""}
+
+// newLayer returns a layer directory changing baseTree.
+func newLayer(t *testing.T) string {
+	dir := t.TempDir()
+	writeTree(t, dir, baseTree)
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("aa"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dir", "c"), []byte("ccc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/c", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "gone")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "gonedir")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func checkLayer(t *testing.T, dir string) {
+	for name, content := range map[string]string{"a": "aa", "dir/b": "b", "dir/c": "ccc"} {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != content {
+			t.Fatalf("expected %s to hold %q, got %q, %v", name, content, b, err)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "dir/c" {
+		t.Fatalf("expected link to dir/c, got %q, %v", target, err)
+	}
+	for _, name := range []string{"gone", "gonedir"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %v", name, err)
+		}
+	}
+}
+
+func TestChangesDirs(t *testing.T) {
+	parent := t.TempDir()
+	writeTree(t, parent, baseTree)
+	layer := newLayer(t)
+
+	changes, err := ChangesDirs(layer, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{
+		{"/a", ChangeModify},
+		{"/dir", ChangeModify},
+		{"/dir/c", ChangeAdd},
+		{"/gone", ChangeDelete},
+		{"/gonedir", ChangeDelete},
+		{"/link", ChangeAdd},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected changes %v, got %v", expected, changes)
+	}
+	if size := ChangesSize(layer, changes); size != 5 {
+		t.Fatalf("expected a size of 5, got %d", size)
+	}
+
+	// applying the changes to the parent recreates the layer
+	rc := ExportChanges(layer, changes)
+	defer rc.Close()
+	size, err := ApplyLayer(parent, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 5 {
+		t.Fatalf("expected 5 bytes applied, got %d", size)
+	}
+	checkLayer(t, parent)
+	if changes, err := ChangesDirs(layer, parent); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes left, got %v, %v", changes, err)
+	}
+}
+
+func writeTar(t *testing.T, headers ...*tar.Header) *bytes.Buffer {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, hdr := range headers {
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+func TestApplyLayer(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, baseTree)
+
+	// an opaque directory hides the content of its parent layer
+	layer := writeTar(t,
+		&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "dir/" + opaqueWhiteout, Mode: 0600},
+		&tar.Header{Name: "dir/new", Mode: 0600},
+		&tar.Header{Name: "../../a", Mode: 0600},
+	)
+	if _, err := ApplyLayer(dir, layer); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "dir"))
+	if err != nil || len(entries) != 1 || entries[0].Name() != "new" {
+		t.Fatalf("expected dir to only hold new, got %v, %v", entries, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "a")); err != nil || len(b) != 0 {
+		t.Fatalf("expected a to be replaced within the layer, got %q, %v", b, err)
+	}
+
+	// files can't be written through symbolic links
+	outside := t.TempDir()
+	layer = writeTar(t,
+		&tar.Header{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: outside},
+		&tar.Header{Name: "escape/file", Mode: 0600},
+	)
+	if _, err := ApplyLayer(dir, layer); err == nil {
+		t.Fatal("expected an error writing through a symbolic link")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file outside of the layer, got %v", err)
+	}
+}
+
+func TestApplyLayerSpecialModes(t *testing.T) {
+	src := t.TempDir()
+	modes := map[string]os.FileMode{
+		"tmp":    0777 | os.ModeSticky,
+		"shared": 0775 | os.ModeSetgid,
+		"su":     0755 | os.ModeSetuid,
+	}
+	for name, mode := range modes {
+		p := filepath.Join(src, name)
+		var err error
+		if mode&os.ModeSetuid != 0 {
+			err = os.WriteFile(p, []byte(name), 0755)
+		} else {
+			err = os.Mkdir(p, 0755)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := ChangesDirs(src, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := ExportChanges(src, changes)
+	defer layer.Close()
+	dir := t.TempDir()
+	if _, err := ApplyLayer(dir, layer); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range modes {
+		fi, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := fi.Mode() &^ os.ModeType; m != mode {
+			t.Fatalf("%s: expected mode %v, got %v", name, mode, m)
+		}
+	}
+}
+
+// protoDriver mounts layers at fixed directories.
+type protoDriver struct {
+	ProtoDriver
+	dirs map[string]string
+
+	mu      sync.Mutex
+	mounted map[string]int
+}
+
+func (d *protoDriver) Get(req *GetRequest) (*GetResponse, error) {
+	dir, ok := d.dirs[req.ID]
+	if !ok {
+		return nil, sdk.NotFound(errors.New("no such layer"))
+	}
+	d.mu.Lock()
+	d.mounted[req.ID]++
+	d.mu.Unlock()
+	return &GetResponse{Dir: dir}, nil
+}
+
+func (d *protoDriver) Put(req *PutRequest) error {
+	d.mu.Lock()
+	d.mounted[req.ID]--
+	d.mu.Unlock()
+	return nil
+}
+
+func TestNaiveDiffDriver(t *testing.T) {
+	base, copy := t.TempDir(), t.TempDir()
+	writeTree(t, base, baseTree)
+	writeTree(t, copy, baseTree)
+	pd := &protoDriver{
+		dirs:    map[string]string{"base": base, "layer": newLayer(t), "copy": copy},
+		mounted: make(map[string]int),
+	}
+	d := NewNaiveDiffDriver(pd)
+
+	res, err := d.Changes(&DiffRequest{ID: "layer", Parent: "base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 6 {
+		t.Fatalf("expected 6 changes, got %v", res.Changes)
+	}
+	size, err := d.DiffSize(&DiffRequest{ID: "layer", Parent: "base"})
+	if err != nil || size.Size != 5 {
+		t.Fatalf("expected a diff size of 5, got %v, %v", size, err)
+	}
+	if _, err := d.Diff(&DiffRequest{ID: "layer", Parent: "unknown"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found diffing from an unknown parent, got %v", err)
+	}
+
+	rc, err := d.Diff(&DiffRequest{ID: "layer", Parent: "base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := d.ApplyDiff(&ApplyDiffRequest{ID: "copy", Parent: "base", Diff: rc})
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied.Size != 5 {
+		t.Fatalf("expected 5 bytes applied, got %d", applied.Size)
+	}
+	checkLayer(t, copy)
+
+	// a layer without parent is exported whole
+	rc, err = d.Diff(&DiffRequest{ID: "layer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(rc)
+	for hdr, err := tr.Next(); err != io.EOF; hdr, err = tr.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	rc.Close()
+	expected := []string{"a", "dir/", "dir/b", "dir/c", "keep/", "link"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+
+	for id, n := range pd.mounted {
+		if n != 0 {
+			t.Fatalf("expected layer %s to be released, mounted %d times", id, n)
+		}
+	}
+}
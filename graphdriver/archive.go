@@ -0,0 +1,397 @@
+package graphdriver
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// whiteoutPrefix prefixes the names of the files marking the deletion
+	// of a file in layer archives.
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout marks a directory whose content replaces the content
+	// of the directory in the parent layer.
+	opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// walkDir returns the tar headers of the files under dir, by path relative
+// to dir in the form /a/b. An empty dir has no files.
+func walkDir(dir string) (map[string]*tar.Header, error) {
+	files := make(map[string]*tar.Header)
+	if dir == "" {
+		return files, nil
+	}
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		hdr, err := fileHeader(p, fi)
+		if err != nil {
+			return err
+		}
+		files["/"+filepath.ToSlash(rel)] = hdr
+		return nil
+	})
+	return files, err
+}
+
+func fileHeader(p string, fi os.FileInfo) (*tar.Header, error) {
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(p); err != nil {
+			return nil, err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return nil, err
+	}
+	// keep the modification times exact, so they compare once applied
+	hdr.Format = tar.FormatPAX
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	return hdr, nil
+}
+
+// changed reports whether the file described by hdr was changed from old.
+// Directories only change with their mode or owner, not with their content,
+// and symbolic links with their target.
+func changed(hdr, old *tar.Header) bool {
+	if hdr.Typeflag != old.Typeflag || hdr.Mode != old.Mode || hdr.Uid != old.Uid || hdr.Gid != old.Gid {
+		return true
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return false
+	case tar.TypeSymlink:
+		return hdr.Linkname != old.Linkname
+	}
+	return hdr.Size != old.Size || !hdr.ModTime.Equal(old.ModTime)
+}
+
+// ChangesDirs returns the changes of the files under newDir from the files
+// under oldDir, sorted by path. With an empty oldDir, every file of newDir
+// is added. Files are compared by their metadata rather than by content, and
+// the directories holding changes are reported as modified.
+func ChangesDirs(newDir, oldDir string) ([]Change, error) {
+	newFiles, err := walkDir(newDir)
+	if err != nil {
+		return nil, err
+	}
+	oldFiles, err := walkDir(oldDir)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]ChangeKind)
+	for p, hdr := range newFiles {
+		if old, ok := oldFiles[p]; !ok {
+			kinds[p] = ChangeAdd
+		} else if changed(hdr, old) {
+			kinds[p] = ChangeModify
+		}
+	}
+	for p := range oldFiles {
+		if _, ok := newFiles[p]; ok {
+			continue
+		}
+		// the deletion of a directory covers its content, only report the
+		// files deleted from directories kept in the new layer
+		if _, ok := oldFiles[path.Dir(p)]; ok && path.Dir(p) != "/" {
+			if _, kept := newFiles[path.Dir(p)]; !kept {
+				continue
+			}
+		}
+		kinds[p] = ChangeDelete
+	}
+	for p := range kinds {
+		for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+			if _, ok := kinds[dir]; ok {
+				break
+			}
+			kinds[dir] = ChangeModify
+		}
+	}
+
+	changes := make([]Change, 0, len(kinds))
+	for p, k := range kinds {
+		changes = append(changes, Change{Path: p, Kind: k})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// ChangesSize returns the size of the files of dir added or modified by
+// changes.
+func ChangesSize(dir string, changes []Change) int64 {
+	var size int64
+	for _, c := range changes {
+		if c.Kind == ChangeDelete {
+			continue
+		}
+		fi, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(c.Path)))
+		if err == nil && fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+	}
+	return size
+}
+
+// ExportChanges returns the tar archive of changes, holding the files of dir
+// added or modified and whiteouts for the deleted ones. changes must be
+// sorted by path, as ChangesDirs returns them.
+func ExportChanges(dir string, changes []Change) io.ReadCloser {
+	return exportChanges(dir, changes, nil)
+}
+
+// exportChanges is ExportChanges, calling done once the archive is written
+// or the reader closed.
+func exportChanges(dir string, changes []Change, done func()) io.ReadCloser {
+	pr, pw := io.Pipe()
+	e := &export{PipeReader: pr, done: make(chan struct{})}
+	go func() {
+		defer close(e.done)
+		err := writeChanges(pw, dir, changes)
+		if done != nil {
+			done()
+		}
+		pw.CloseWithError(err)
+	}()
+	return e
+}
+
+// export is the reader of an archive written by exportChanges.
+type export struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+// Close stops writing the archive, and waits for it to be done.
+func (e *export) Close() error {
+	err := e.PipeReader.Close()
+	<-e.done
+	return err
+}
+
+func writeChanges(w io.Writer, dir string, changes []Change) error {
+	tw := tar.NewWriter(w)
+	for _, c := range changes {
+		name := strings.TrimPrefix(c.Path, "/")
+		if c.Kind == ChangeDelete {
+			hdr := &tar.Header{
+				Name:     path.Join(path.Dir(name), whiteoutPrefix+path.Base(name)),
+				Typeflag: tar.TypeReg,
+				Mode:     0600,
+				ModTime:  time.Now(),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		hdr, err := fileHeader(p, fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			if err := copyFile(tw, p); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func copyFile(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// ApplyLayer extracts the tar archive of a layer into dir, applying its
+// whiteouts, and returns the size of the files it extracted. Entries can't
+// reach outside of dir, through their path or symbolic links.
+func ApplyLayer(dir string, layer io.Reader) (int64, error) {
+	var size int64
+	var dirs []*tar.Header
+	tr := tar.NewReader(layer)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return size, err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		if err := checkPath(dir, name); err != nil {
+			return size, err
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		parent, base := filepath.Dir(p), path.Base(name)
+
+		if base == opaqueWhiteout {
+			if err := removeContent(parent); err != nil {
+				return size, err
+			}
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			if err := os.RemoveAll(filepath.Join(parent, strings.TrimPrefix(base, whiteoutPrefix))); err != nil {
+				return size, err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return size, err
+		}
+		// entries replace existing files, but directories are merged
+		if fi, err := os.Lstat(p); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(p); err != nil {
+				return size, err
+			}
+		}
+
+		mode := fileMode(hdr)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(p, mode); err != nil && !os.IsExist(err) {
+				return size, err
+			}
+			// the times of directories are set once their content is extracted
+			hdr.Name = p
+			dirs = append(dirs, hdr)
+		case tar.TypeReg:
+			n, err := extractFile(p, mode, tr)
+			size += n
+			if err != nil {
+				return size, err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, p); err != nil {
+				return size, err
+			}
+		case tar.TypeLink:
+			target := path.Clean("/" + hdr.Linkname)
+			if err := checkPath(dir, target); err != nil {
+				return size, err
+			}
+			if err := os.Link(filepath.Join(dir, filepath.FromSlash(target)), p); err != nil {
+				return size, err
+			}
+		default:
+			return size, fmt.Errorf("unsupported entry %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+
+		if os.Geteuid() == 0 {
+			if err := os.Lchown(p, hdr.Uid, hdr.Gid); err != nil {
+				return size, err
+			}
+		}
+		if hdr.Typeflag != tar.TypeSymlink && hdr.Typeflag != tar.TypeLink {
+			if err := os.Chmod(p, mode); err != nil {
+				return size, err
+			}
+			if hdr.Typeflag != tar.TypeDir {
+				if err := os.Chtimes(p, hdr.ModTime, hdr.ModTime); err != nil {
+					return size, err
+				}
+			}
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].Name, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return size, err
+		}
+	}
+	return size, nil
+}
+
+// fileMode returns the permissions of the file of hdr, with its setuid,
+// setgid and sticky bits.
+func fileMode(hdr *tar.Header) os.FileMode {
+	return hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// checkPath checks that none of the parents of the file at name, in the
+// form /a/b, is a symbolic link under dir.
+func checkPath(dir, name string) error {
+	p := dir
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for _, part := range parts[:len(parts)-1] {
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s: parent %s is a symbolic link", name, part)
+		}
+	}
+	return nil
+}
+
+func removeContent(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(p string, mode os.FileMode, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
@@ -0,0 +1,130 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package graphdriver
+
+import (
+	"io"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Driver, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Driver = (*Client)(nil)
+
+// Init calls the Init method of the plugin.
+func (c *Client) Init(req *InitRequest) error {
+	return c.client.Call(initPath, req, nil)
+}
+
+// Create calls the Create method of the plugin.
+func (c *Client) Create(req *CreateRequest) error {
+	return c.client.Call(createPath, req, nil)
+}
+
+// CreateReadWrite calls the CreateReadWrite method of the plugin.
+func (c *Client) CreateReadWrite(req *CreateRequest) error {
+	return c.client.Call(createReadWritePath, req, nil)
+}
+
+// Remove calls the Remove method of the plugin.
+func (c *Client) Remove(req *RemoveRequest) error {
+	return c.client.Call(removePath, req, nil)
+}
+
+// Get calls the Get method of the plugin.
+func (c *Client) Get(req *GetRequest) (*GetResponse, error) {
+	res := &GetResponse{}
+	if err := c.client.Call(getPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Put calls the Put method of the plugin.
+func (c *Client) Put(req *PutRequest) error {
+	return c.client.Call(putPath, req, nil)
+}
+
+// Exists calls the Exists method of the plugin.
+func (c *Client) Exists(req *ExistsRequest) (*ExistsResponse, error) {
+	res := &ExistsResponse{}
+	if err := c.client.Call(existsPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Status calls the Status method of the plugin.
+func (c *Client) Status() (*StatusResponse, error) {
+	res := &StatusResponse{}
+	if err := c.client.Call(statusPath, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetMetadata calls the GetMetadata method of the plugin.
+func (c *Client) GetMetadata(req *GetMetadataRequest) (*GetMetadataResponse, error) {
+	res := &GetMetadataResponse{}
+	if err := c.client.Call(getMetadataPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Cleanup calls the Cleanup method of the plugin.
+func (c *Client) Cleanup() error {
+	return c.client.Call(cleanupPath, nil, nil)
+}
+
+// Diff calls the Diff method of the plugin.
+func (c *Client) Diff(req *DiffRequest) (io.ReadCloser, error) {
+	return c.client.Stream(diffPath, req)
+}
+
+// Changes calls the Changes method of the plugin.
+func (c *Client) Changes(req *DiffRequest) (*ChangesResponse, error) {
+	res := &ChangesResponse{}
+	if err := c.client.Call(changesPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ApplyDiff calls the ApplyDiff method of the plugin.
+func (c *Client) ApplyDiff(req *ApplyDiffRequest) (*ApplyDiffResponse, error) {
+	query, body := req.encodeHTTP()
+	res := &ApplyDiffResponse{}
+	if err := c.client.Send(applyDiffPath+"?"+query.Encode(), body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DiffSize calls the DiffSize method of the plugin.
+func (c *Client) DiffSize(req *DiffRequest) (*DiffSizeResponse, error) {
+	res := &DiffSizeResponse{}
+	if err := c.client.Call(diffSizePath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Capabilities calls the Capabilities method of the plugin.
+func (c *Client) Capabilities() (*CapabilitiesResponse, error) {
+	res := &CapabilitiesResponse{}
+	if err := c.client.Call(capabilitiesPath, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
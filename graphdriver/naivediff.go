@@ -0,0 +1,110 @@
+package graphdriver
+
+import (
+	"io"
+	"sync"
+)
+
+// ProtoDriver is a graph driver without the diff methods, which
+// NewNaiveDiffDriver implements on top of it.
+type ProtoDriver interface {
+	Init(*InitRequest) error
+	Create(*CreateRequest) error
+	CreateReadWrite(*CreateRequest) error
+	Remove(*RemoveRequest) error
+	Get(*GetRequest) (*GetResponse, error)
+	Put(*PutRequest) error
+	Exists(*ExistsRequest) (*ExistsResponse, error)
+	Status() (*StatusResponse, error)
+	GetMetadata(*GetMetadataRequest) (*GetMetadataResponse, error)
+	Cleanup() error
+	Capabilities() (*CapabilitiesResponse, error)
+}
+
+type naiveDiffDriver struct {
+	ProtoDriver
+}
+
+// NewNaiveDiffDriver returns a Driver computing the diffs of layers by
+// comparing the directories driver mounts them at with Get. Layers are
+// released with Put once a diff is done.
+func NewNaiveDiffDriver(driver ProtoDriver) Driver {
+	return &naiveDiffDriver{driver}
+}
+
+// mount mounts the layer id, and returns its directory and the function
+// releasing it. An empty id mounts nothing.
+func (d *naiveDiffDriver) mount(id string) (string, func(), error) {
+	if id == "" {
+		return "", func() {}, nil
+	}
+	res, err := d.Get(&GetRequest{ID: id})
+	if err != nil {
+		return "", nil, err
+	}
+	var once sync.Once
+	return res.Dir, func() { once.Do(func() { d.Put(&PutRequest{ID: id}) }) }, nil
+}
+
+// changes mounts the layer id and its parent, and returns the changes
+// between them along with the function releasing both.
+func (d *naiveDiffDriver) changes(id, parent string) (string, []Change, func(), error) {
+	dir, put, err := d.mount(id)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	parentDir, putParent, err := d.mount(parent)
+	if err != nil {
+		put()
+		return "", nil, nil, err
+	}
+	release := func() {
+		putParent()
+		put()
+	}
+	changes, err := ChangesDirs(dir, parentDir)
+	if err != nil {
+		release()
+		return "", nil, nil, err
+	}
+	return dir, changes, release, nil
+}
+
+func (d *naiveDiffDriver) Diff(req *DiffRequest) (io.ReadCloser, error) {
+	dir, changes, release, err := d.changes(req.ID, req.Parent)
+	if err != nil {
+		return nil, err
+	}
+	return exportChanges(dir, changes, release), nil
+}
+
+func (d *naiveDiffDriver) Changes(req *DiffRequest) (*ChangesResponse, error) {
+	_, changes, release, err := d.changes(req.ID, req.Parent)
+	if err != nil {
+		return nil, err
+	}
+	release()
+	return &ChangesResponse{Changes: changes}, nil
+}
+
+func (d *naiveDiffDriver) ApplyDiff(req *ApplyDiffRequest) (*ApplyDiffResponse, error) {
+	dir, put, err := d.mount(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer put()
+	size, err := ApplyLayer(dir, req.Diff)
+	if err != nil {
+		return nil, err
+	}
+	return &ApplyDiffResponse{Size: size}, nil
+}
+
+func (d *naiveDiffDriver) DiffSize(req *DiffRequest) (*DiffSizeResponse, error) {
+	dir, changes, release, err := d.changes(req.ID, req.Parent)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return &DiffSizeResponse{Size: ChangesSize(dir, changes)}, nil
+}
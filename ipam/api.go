@@ -1,11 +1,13 @@
 package ipam
 
 import (
-	"net/http"
+	"encoding/json"
 
 	"github.com/docker/go-plugins-helpers/sdk"
 )
 
+//go:generate go run ../cmd/protocolgen
+
 const (
 	manifest = `{"Implements": ["IpamDriver"]}`
 
@@ -17,16 +19,6 @@ const (
 	releaseAddressPath = "/IpamDriver.ReleaseAddress"
 )
 
-// Ipam represent the interface a driver must fulfill.
-type Ipam interface {
-	GetCapabilities() (*CapabilitiesResponse, error)
-	GetDefaultAddressSpaces() (*AddressSpacesResponse, error)
-	RequestPool(*RequestPoolRequest) (*RequestPoolResponse, error)
-	ReleasePool(*ReleasePoolRequest) error
-	RequestAddress(*RequestAddressRequest) (*RequestAddressResponse, error)
-	ReleaseAddress(*ReleaseAddressRequest) error
-}
-
 // CapabilitiesResponse returns whether or not this IPAM required pre-made MAC
 type CapabilitiesResponse struct {
 	RequiresMACAddress bool
@@ -45,6 +37,9 @@ type RequestPoolRequest struct {
 	SubPool      string
 	Options      map[string]string
 	V6           bool
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // RequestPoolResponse returns a registered address pool with the IPAM driver
@@ -57,6 +52,9 @@ type RequestPoolResponse struct {
 // ReleasePoolRequest is sent when releasing a previously registered address pool
 type ReleasePoolRequest struct {
 	PoolID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // RequestAddressRequest is sent when requesting an address from IPAM
@@ -64,6 +62,9 @@ type RequestAddressRequest struct {
 	PoolID  string
 	Address string
 	Options map[string]string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // RequestAddressResponse is formed with allocated address by IPAM
@@ -76,6 +77,9 @@ type RequestAddressResponse struct {
 type ReleaseAddressRequest struct {
 	PoolID  string
 	Address string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // ErrorResponse is a formatted error message that libnetwork can understand
@@ -100,74 +104,3 @@ func NewHandler(ipam Ipam) *Handler {
 	h.initMux()
 	return h
 }
-
-func (h *Handler) initMux() {
-	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
-		res, err := h.ipam.GetCapabilities()
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(addressSpacesPath, func(w http.ResponseWriter, r *http.Request) {
-		res, err := h.ipam.GetDefaultAddressSpaces()
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(requestPoolPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &RequestPoolRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.ipam.RequestPool(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(releasePoolPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &ReleasePoolRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.ipam.ReleasePool(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(requestAddressPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &RequestAddressRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.ipam.RequestAddress(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(releaseAddressPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &ReleaseAddressRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.ipam.ReleaseAddress(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-}
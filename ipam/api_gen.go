@@ -0,0 +1,84 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package ipam
+
+import (
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Ipam represent the interface a driver must fulfill.
+type Ipam interface {
+	GetCapabilities() (*CapabilitiesResponse, error)
+	GetDefaultAddressSpaces() (*AddressSpacesResponse, error)
+	RequestPool(*RequestPoolRequest) (*RequestPoolResponse, error)
+	ReleasePool(*ReleasePoolRequest) error
+	RequestAddress(*RequestAddressRequest) (*RequestAddressResponse, error)
+	ReleaseAddress(*ReleaseAddressRequest) error
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.ipam.GetCapabilities()
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(addressSpacesPath, func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.ipam.GetDefaultAddressSpaces()
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(requestPoolPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &RequestPoolRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.ipam.RequestPool(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(releasePoolPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ReleasePoolRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.ipam.ReleasePool(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(requestAddressPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &RequestAddressRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.ipam.RequestAddress(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(releaseAddressPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ReleaseAddressRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.ipam.ReleaseAddress(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+}
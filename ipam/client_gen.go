@@ -0,0 +1,64 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package ipam
+
+import "github.com/docker/go-plugins-helpers/sdk"
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Ipam, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Ipam = (*Client)(nil)
+
+// GetCapabilities calls the GetCapabilities method of the plugin.
+func (c *Client) GetCapabilities() (*CapabilitiesResponse, error) {
+	res := &CapabilitiesResponse{}
+	if err := c.client.Call(capabilitiesPath, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetDefaultAddressSpaces calls the GetDefaultAddressSpaces method of the plugin.
+func (c *Client) GetDefaultAddressSpaces() (*AddressSpacesResponse, error) {
+	res := &AddressSpacesResponse{}
+	if err := c.client.Call(addressSpacesPath, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RequestPool calls the RequestPool method of the plugin.
+func (c *Client) RequestPool(req *RequestPoolRequest) (*RequestPoolResponse, error) {
+	res := &RequestPoolResponse{}
+	if err := c.client.Call(requestPoolPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReleasePool calls the ReleasePool method of the plugin.
+func (c *Client) ReleasePool(req *ReleasePoolRequest) error {
+	return c.client.Call(releasePoolPath, req, nil)
+}
+
+// RequestAddress calls the RequestAddress method of the plugin.
+func (c *Client) RequestAddress(req *RequestAddressRequest) (*RequestAddressResponse, error) {
+	res := &RequestAddressResponse{}
+	if err := c.client.Call(requestAddressPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReleaseAddress calls the ReleaseAddress method of the plugin.
+func (c *Client) ReleaseAddress(req *ReleaseAddressRequest) error {
+	return c.client.Call(releaseAddressPath, req, nil)
+}
@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+//go:generate go run ../cmd/protocolgen
+
+const (
+	manifest         = `{"Implements": ["LogDriver"]}`
+	startLoggingPath = "/LogDriver.StartLogging"
+	stopLoggingPath  = "/LogDriver.StopLogging"
+	capabilitiesPath = "/LogDriver.Capabilities"
+	readLogsPath     = "/LogDriver.ReadLogs"
+)
+
+// StartLoggingRequest is sent when a container using the driver starts. The
+// daemon writes the log entries of the container to the FIFO at File.
+type StartLoggingRequest struct {
+	File string
+	Info Info
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// StopLoggingRequest is sent when a container using the driver stops.
+type StopLoggingRequest struct {
+	File string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// CapabilitiesResponse structure for a log driver capability response
+type CapabilitiesResponse struct {
+	Cap Capability
+}
+
+// Capability represents the list of capabilities a log driver can return
+type Capability struct {
+	// ReadLogs is set by drivers able to serve docker logs.
+	ReadLogs bool
+}
+
+// ReadLogsRequest is sent to read the logs of a container, for docker logs.
+type ReadLogsRequest struct {
+	Info   Info
+	Config ReadConfig
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// ReadConfig selects the log entries to read.
+type ReadConfig struct {
+	// Since and Until bound the times of the entries, when not zero.
+	Since time.Time
+	Until time.Time
+	// Tail is the number of entries to read from the end of the logs, or
+	// -1 to read all of them.
+	Tail int
+	// Follow is set to keep streaming the entries logged after the request.
+	Follow bool
+}
+
+// Info describes the container a log stream belongs to.
+type Info struct {
+	Config              map[string]string
+	ContainerID         string
+	ContainerName       string
+	ContainerEntrypoint string
+	ContainerArgs       []string
+	ContainerImageID    string
+	ContainerImageName  string
+	ContainerCreated    time.Time
+	ContainerEnv        []string
+	ContainerLabels     map[string]string
+	LogPath             string
+	DaemonName          string
+}
+
+// Driver represent the interface a log driver must fulfill.
+type Driver interface {
+	// StartLogging returns the Logger receiving the log entries of the
+	// container described by req, until it stops.
+	StartLogging(req *StartLoggingRequest) (Logger, error)
+	Capabilities() *CapabilitiesResponse
+	// ReadLogs returns the log entries selected by req as a stream of
+	// entries, see EntryEncoder. It is only called when the capabilities of
+	// the driver include ReadLogs.
+	ReadLogs(req *ReadLogsRequest) (io.ReadCloser, error)
+}
+
+// Logger receives the log entries of a container.
+type Logger interface {
+	// Log is called for every entry the container logs. Errors are logged
+	// and do not interrupt the stream.
+	Log(entry *LogEntry) error
+	// Close is called once the container stopped logging.
+	Close() error
+}
+
+// Handler forwards requests and responses between the docker daemon and the plugin.
+type Handler struct {
+	protocol Protocol
+	sdk.Handler
+}
+
+// NewHandler initializes the request handler with a driver implementation.
+// The handler reads the FIFOs of the containers and feeds their entries to
+// the loggers of driver.
+func NewHandler(driver Driver) *Handler {
+	return NewProtocolHandler(newStreams(driver))
+}
+
+// NewProtocolHandler initializes the request handler with an implementation
+// of the bare protocol, for drivers reading the FIFOs themselves.
+func NewProtocolHandler(protocol Protocol) *Handler {
+	h := &Handler{protocol, sdk.NewHandler(manifest)}
+	h.initMux()
+	return h
+}
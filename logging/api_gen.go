@@ -0,0 +1,58 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package logging
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Protocol represent the methods of the log driver protocol, as called by the daemon.
+type Protocol interface {
+	StartLogging(*StartLoggingRequest) error
+	StopLogging(*StopLoggingRequest) error
+	Capabilities() *CapabilitiesResponse
+	ReadLogs(*ReadLogsRequest) (io.ReadCloser, error)
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(startLoggingPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &StartLoggingRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.protocol.StartLogging(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(stopLoggingPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &StopLoggingRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.protocol.StopLogging(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
+		sdk.EncodeResponse(w, h.protocol.Capabilities(), false)
+	})
+	h.HandleFunc(readLogsPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ReadLogsRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.protocol.ReadLogs(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.StreamResponseContext(r.Context(), w, res)
+	})
+}
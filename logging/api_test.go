@@ -0,0 +1,280 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+func TestLogEntryEncoding(t *testing.T) {
+	entry := &LogEntry{
+		Source:             "stdout",
+		TimeNano:           1,
+		Line:               []byte("hi"),
+		Partial:            true,
+		PartialLogMetadata: &PartialLogEntryMetadata{Last: true, ID: "a", Ordinal: 2},
+	}
+	expected := []byte("\x0a\x06stdout\x10\x01\x1a\x02hi\x20\x01\x2a\x07\x08\x01\x12\x01a\x18\x02")
+	b := entry.Marshal()
+	if !bytes.Equal(b, expected) {
+		t.Fatalf("expected %q, got %q", expected, b)
+	}
+
+	// unknown fields are skipped
+	b = append(b, "\x4d\x00\x00\x00\x00\x52\x01x"...)
+	var decoded LogEntry
+	if err := decoded.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, entry) {
+		t.Fatalf("expected %+v, got %+v", entry, &decoded)
+	}
+
+	if err := decoded.Unmarshal([]byte("\x0a\x06std")); err == nil {
+		t.Fatal("expected an error decoding a truncated entry")
+	}
+}
+
+func TestEntryStream(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEntryEncoder(&buf)
+	for _, line := range []string{"one", "two"} {
+		if err := enc.Encode(&LogEntry{Source: "stdout", Line: []byte(line)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.WriteString("\x00\x00\x00\x10\x0a")
+
+	dec := NewEntryDecoder(&buf)
+	for _, line := range []string{"one", "two"} {
+		var entry LogEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if string(entry.Line) != line {
+			t.Fatalf("expected line %q, got %q", line, entry.Line)
+		}
+	}
+	var entry LogEntry
+	if err := dec.Decode(&entry); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if err := dec.Decode(&entry); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+type testLogger struct {
+	mu     sync.Mutex
+	lines  []string
+	closed bool
+}
+
+func (l *testLogger) Log(entry *LogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, string(entry.Line))
+	return nil
+}
+
+func (l *testLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
+
+type testDriver struct {
+	loggers map[string]*testLogger
+	// live is the feed of ReadLogs, which fails when it is nil
+	live   chan *LogEntry
+	closed chan struct{}
+}
+
+func (d *testDriver) StartLogging(req *StartLoggingRequest) (Logger, error) {
+	l := &testLogger{}
+	d.loggers[req.Info.ContainerID] = l
+	return l, nil
+}
+
+func (d *testDriver) Capabilities() *CapabilitiesResponse {
+	return &CapabilitiesResponse{Cap: Capability{ReadLogs: false}}
+}
+
+func (d *testDriver) ReadLogs(req *ReadLogsRequest) (io.ReadCloser, error) {
+	if d.live == nil {
+		return nil, sdk.NotFound(errors.New("no logs"))
+	}
+	return &notifyCloser{NewEntryStream(req.Config, testHistory, d.live), d.closed}, nil
+}
+
+type notifyCloser struct {
+	io.ReadCloser
+	closed chan struct{}
+}
+
+func (c *notifyCloser) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return c.ReadCloser.Close()
+}
+
+// testHistory yields entries one to five, logged at the second of their
+// line.
+func testHistory(yield func(*LogEntry) bool) {
+	for i := 1; i <= 5; i++ {
+		if !yield(&LogEntry{Line: []byte(fmt.Sprint(i)), TimeNano: int64(i) * int64(time.Second)}) {
+			return
+		}
+	}
+}
+
+func readLines(t *testing.T, r io.Reader) string {
+	var lines []string
+	dec := NewEntryDecoder(r)
+	for {
+		var entry LogEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return strings.Join(lines, ",")
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(entry.Line))
+	}
+}
+
+func TestEntryStreamConfig(t *testing.T) {
+	for _, c := range []struct {
+		cfg      ReadConfig
+		expected string
+	}{
+		{ReadConfig{Tail: -1}, "1,2,3,4,5"},
+		{ReadConfig{Tail: 0}, ""},
+		{ReadConfig{Tail: 2}, "4,5"},
+		{ReadConfig{Tail: 10}, "1,2,3,4,5"},
+		{ReadConfig{Tail: -1, Since: time.Unix(2, 0), Until: time.Unix(4, 0)}, "2,3,4"},
+		{ReadConfig{Tail: 1, Until: time.Unix(3, 0)}, "3"},
+		// live entries are not read without Follow
+		{ReadConfig{Tail: -1, Since: time.Unix(5, 0)}, "5"},
+	} {
+		live := make(chan *LogEntry, 1)
+		live <- &LogEntry{Line: []byte("live")}
+		s := NewEntryStream(c.cfg, testHistory, live)
+		if got := readLines(t, s); got != c.expected {
+			t.Fatalf("%+v: expected %q, got %q", c.cfg, c.expected, got)
+		}
+		s.Close()
+	}
+
+	// following ends with the live feed or at Until
+	live := make(chan *LogEntry, 2)
+	live <- &LogEntry{Line: []byte("6"), TimeNano: 6 * int64(time.Second)}
+	live <- &LogEntry{Line: []byte("7"), TimeNano: 7 * int64(time.Second)}
+	close(live)
+	s := NewEntryStream(ReadConfig{Tail: 1, Follow: true}, testHistory, live)
+	if got := readLines(t, s); got != "5,6,7" {
+		t.Fatalf("expected 5,6,7, got %q", got)
+	}
+	s = NewEntryStream(ReadConfig{Tail: -1, Follow: true, Until: time.Now().Add(10 * time.Millisecond)}, nil, make(chan *LogEntry))
+	if got := readLines(t, s); got != "" {
+		t.Fatalf("expected no entries, got %q", got)
+	}
+}
+
+func TestReadLogsFollow(t *testing.T) {
+	d := &testDriver{live: make(chan *LogEntry), closed: make(chan struct{})}
+	h := NewHandler(d)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	rc, err := c.ReadLogs(&ReadLogsRequest{Config: ReadConfig{Tail: 1, Follow: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewEntryDecoder(rc)
+	var entry LogEntry
+	if err := dec.Decode(&entry); err != nil || string(entry.Line) != "5" {
+		t.Fatalf("expected entry 5, got %q: %v", entry.Line, err)
+	}
+	d.live <- &LogEntry{Line: []byte("live")}
+	if err := dec.Decode(&entry); err != nil || string(entry.Line) != "live" {
+		t.Fatalf("expected live entry, got %q: %v", entry.Line, err)
+	}
+
+	// the stream is closed once the client disconnects
+	rc.Close()
+	select {
+	case <-d.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed after the client disconnected")
+	}
+}
+
+func TestHandlerStreams(t *testing.T) {
+	// a regular file stands for the FIFO the daemon writes to
+	file := filepath.Join(t.TempDir(), "fifo")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := NewEntryEncoder(f)
+	for _, line := range []string{"one", "two"} {
+		if err := enc.Encode(&LogEntry{Source: "stdout", Line: []byte(line)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	d := &testDriver{loggers: make(map[string]*testLogger)}
+	h := NewHandler(d)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	start := &StartLoggingRequest{File: file, Info: Info{ContainerID: "c1"}}
+	if err := c.StartLogging(start); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartLogging(start); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict starting twice, got %v", err)
+	}
+	if err := c.StopLogging(&StopLoggingRequest{File: file}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StopLogging(&StopLoggingRequest{File: file}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found stopping twice, got %v", err)
+	}
+
+	logger := d.loggers["c1"]
+	if !logger.closed {
+		t.Fatal("expected the logger to be closed")
+	}
+	if !reflect.DeepEqual(logger.lines, []string{"one", "two"}) {
+		t.Fatalf("expected lines one and two, got %q", logger.lines)
+	}
+
+	if c.Capabilities().Cap.ReadLogs {
+		t.Fatal("expected ReadLogs capability to be false")
+	}
+	if _, err := c.ReadLogs(&ReadLogsRequest{}); err == nil || err.Error() != "no logs" {
+		t.Fatalf("expected error no logs, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package logging
+
+import (
+	"io"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Protocol, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Protocol = (*Client)(nil)
+
+// StartLogging calls the StartLogging method of the plugin.
+func (c *Client) StartLogging(req *StartLoggingRequest) error {
+	return c.client.Call(startLoggingPath, req, nil)
+}
+
+// StopLogging calls the StopLogging method of the plugin.
+func (c *Client) StopLogging(req *StopLoggingRequest) error {
+	return c.client.Call(stopLoggingPath, req, nil)
+}
+
+// Capabilities calls the Capabilities method of the plugin.
+// Errors are reported as an empty response.
+func (c *Client) Capabilities() *CapabilitiesResponse {
+	res := &CapabilitiesResponse{}
+	if err := c.client.Call(capabilitiesPath, nil, res); err != nil {
+		return &CapabilitiesResponse{}
+	}
+	return res
+}
+
+// ReadLogs calls the ReadLogs method of the plugin.
+func (c *Client) ReadLogs(req *ReadLogsRequest) (io.ReadCloser, error) {
+	return c.client.Stream(readLogsPath, req)
+}
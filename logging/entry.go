@@ -0,0 +1,232 @@
+package logging
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// maxEntrySize is the largest encoded entry accepted, the limit the daemon
+// uses as well.
+const maxEntrySize = 1e6
+
+// LogEntry is a log message of a container. Its binary encoding is the one
+// of the LogEntry protocol buffer message exchanged with the daemon.
+type LogEntry struct {
+	Source   string
+	TimeNano int64
+	Line     []byte
+	// Partial is set for the chunks of a line too long to be sent at once,
+	// but the last one.
+	Partial            bool
+	PartialLogMetadata *PartialLogEntryMetadata
+}
+
+// PartialLogEntryMetadata identifies the chunks of a partial line.
+type PartialLogEntryMetadata struct {
+	Last    bool
+	ID      string
+	Ordinal int32
+}
+
+// Time returns the time the entry was logged at.
+func (e *LogEntry) Time() time.Time {
+	return time.Unix(0, e.TimeNano)
+}
+
+// protocol buffer wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Marshal returns the protocol buffer encoding of e.
+func (e *LogEntry) Marshal() []byte {
+	var b []byte
+	if e.Source != "" {
+		b = appendBytes(b, 1, []byte(e.Source))
+	}
+	if e.TimeNano != 0 {
+		b = appendVarint(b, 2, uint64(e.TimeNano))
+	}
+	if len(e.Line) > 0 {
+		b = appendBytes(b, 3, e.Line)
+	}
+	if e.Partial {
+		b = appendVarint(b, 4, 1)
+	}
+	if m := e.PartialLogMetadata; m != nil {
+		var mb []byte
+		if m.Last {
+			mb = appendVarint(mb, 1, 1)
+		}
+		if m.ID != "" {
+			mb = appendBytes(mb, 2, []byte(m.ID))
+		}
+		if m.Ordinal != 0 {
+			mb = appendVarint(mb, 3, uint64(int64(m.Ordinal)))
+		}
+		b = appendBytes(b, 5, mb)
+	}
+	return b
+}
+
+// Unmarshal decodes the protocol buffer encoding of an entry into e. Unknown
+// fields are skipped.
+func (e *LogEntry) Unmarshal(b []byte) error {
+	*e = LogEntry{}
+	return readFields(b, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			e.Source = string(data)
+		case 2:
+			e.TimeNano = int64(v)
+		case 3:
+			e.Line = append([]byte(nil), data...)
+		case 4:
+			e.Partial = v != 0
+		case 5:
+			m := &PartialLogEntryMetadata{}
+			if err := m.unmarshal(data); err != nil {
+				return err
+			}
+			e.PartialLogMetadata = m
+		}
+		return nil
+	})
+}
+
+func (m *PartialLogEntryMetadata) unmarshal(b []byte) error {
+	return readFields(b, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.Last = v != 0
+		case 2:
+			m.ID = string(data)
+		case 3:
+			m.Ordinal = int32(v)
+		}
+		return nil
+	})
+}
+
+func appendVarint(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytes(b []byte, num int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+var errMalformedEntry = errors.New("logging: malformed log entry")
+
+// readFields calls fn for every field of the protocol buffer message b, with
+// the value of varint fields or the content of length delimited ones.
+func readFields(b []byte, fn func(num int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 || key>>3 == 0 || key>>3 > math.MaxInt32 {
+			return errMalformedEntry
+		}
+		b = b[n:]
+		num := int(key >> 3)
+
+		var v uint64
+		var data []byte
+		switch key & 7 {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformedEntry
+			}
+		case wireFixed64:
+			n = 8
+		case wireFixed32:
+			n = 4
+		case wireBytes:
+			l, ln := binary.Uvarint(b)
+			if ln <= 0 || l > uint64(len(b)-ln) {
+				return errMalformedEntry
+			}
+			data = b[ln : ln+int(l)]
+			n = ln + int(l)
+		default:
+			return fmt.Errorf("logging: unsupported wire type %d in log entry", key&7)
+		}
+		if n > len(b) {
+			return errMalformedEntry
+		}
+		if err := fn(num, v, data); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// EntryEncoder writes log entries the way the daemon sends them to log
+// drivers, each prefixed with its size as a big endian uint32.
+type EntryEncoder struct {
+	w io.Writer
+}
+
+// NewEntryEncoder creates an EntryEncoder writing to w.
+func NewEntryEncoder(w io.Writer) *EntryEncoder {
+	return &EntryEncoder{w: w}
+}
+
+// Encode writes entry to the stream.
+func (enc *EntryEncoder) Encode(entry *LogEntry) error {
+	b := entry.Marshal()
+	if len(b) > maxEntrySize {
+		return fmt.Errorf("logging: log entry of %d bytes exceeds the maximum size", len(b))
+	}
+	buf := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+	_, err := enc.w.Write(buf)
+	return err
+}
+
+// EntryDecoder reads log entries written by an EntryEncoder or the daemon.
+type EntryDecoder struct {
+	r   io.Reader
+	buf []byte
+}
+
+// NewEntryDecoder creates an EntryDecoder reading from r.
+func NewEntryDecoder(r io.Reader) *EntryDecoder {
+	return &EntryDecoder{r: r}
+}
+
+// Decode reads the next entry of the stream into entry. It returns io.EOF at
+// the end of the stream and io.ErrUnexpectedEOF when it ends within an entry.
+func (dec *EntryDecoder) Decode(entry *LogEntry) error {
+	var size [4]byte
+	if _, err := io.ReadFull(dec.r, size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxEntrySize {
+		return fmt.Errorf("logging: log entry of %d bytes exceeds the maximum size", n)
+	}
+	if cap(dec.buf) < int(n) {
+		dec.buf = make([]byte, n)
+	}
+	dec.buf = dec.buf[:n]
+	if _, err := io.ReadFull(dec.r, dec.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return entry.Unmarshal(dec.buf)
+}
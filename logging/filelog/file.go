@@ -0,0 +1,182 @@
+package filelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/logging"
+)
+
+// logFile is the set of log files of a container: the file being written and
+// the rotated ones.
+type logFile struct {
+	dir  string
+	opts options
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64 // bytes of f holding complete entries
+	// gen is incremented on every rotation, so that readers following f
+	// notice it was replaced.
+	gen    int
+	closed bool
+	// notify is closed and replaced whenever entries are written, f is
+	// rotated or closed.
+	notify chan struct{}
+}
+
+func openLogFile(dir string, opts options) (*logFile, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	lf := &logFile{dir: dir, opts: opts, notify: make(chan struct{})}
+	if err := lf.open(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// open opens the file being written, appending to the one left by a
+// previous run of the container.
+func (lf *logFile) open() error {
+	f, err := os.OpenFile(filepath.Join(lf.dir, logName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	lf.f, lf.size = f, fi.Size()
+	return nil
+}
+
+// rotatedName is the name of the rotated file at index i, 1 being the most
+// recent one.
+func (lf *logFile) rotatedName(i int, compressed bool) string {
+	name := filepath.Join(lf.dir, logName+"."+strconv.Itoa(i))
+	if compressed {
+		name += ".gz"
+	}
+	return name
+}
+
+func (lf *logFile) write(entry *logging.LogEntry) error {
+	var buf bytes.Buffer
+	if err := logging.NewEntryEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.closed {
+		return fmt.Errorf("log file of %s is closed", lf.dir)
+	}
+	if lf.opts.maxSize > 0 && lf.size > 0 && lf.size+int64(buf.Len()) > lf.opts.maxSize {
+		if err := lf.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := lf.f.Write(buf.Bytes())
+	if err != nil {
+		// drop the partial entry, readers only read complete ones
+		lf.f.Truncate(lf.size)
+		return err
+	}
+	lf.size += int64(n)
+	lf.broadcast()
+	return nil
+}
+
+// rotate shifts the rotated files, dropping the oldest one, and replaces
+// the file being written by an empty one. With a single file, the file is
+// simply truncated.
+func (lf *logFile) rotate() error {
+	if err := lf.f.Close(); err != nil {
+		return err
+	}
+	current := filepath.Join(lf.dir, logName)
+	if lf.opts.maxFile > 1 {
+		for i := lf.opts.maxFile - 1; i > 0; i-- {
+			for _, compressed := range []bool{false, true} {
+				name := lf.rotatedName(i, compressed)
+				var err error
+				if i == lf.opts.maxFile-1 {
+					err = os.Remove(name)
+				} else {
+					err = os.Rename(name, lf.rotatedName(i+1, compressed))
+				}
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		if err := os.Rename(current, lf.rotatedName(1, false)); err != nil {
+			return err
+		}
+		if lf.opts.compress {
+			if err := compressFile(lf.rotatedName(1, false), lf.rotatedName(1, true)); err != nil {
+				return err
+			}
+		}
+	} else if err := os.Remove(current); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lf.gen++
+	return lf.open()
+}
+
+// compressFile replaces the file src by its gzip compressed copy dst.
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (lf *logFile) close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.closed {
+		return nil
+	}
+	lf.closed = true
+	lf.broadcast()
+	return lf.f.Close()
+}
+
+// broadcast wakes up the readers following the file. It must be called with
+// mu held.
+func (lf *logFile) broadcast() {
+	close(lf.notify)
+	lf.notify = make(chan struct{})
+}
@@ -0,0 +1,184 @@
+// Package filelog implements a log driver writing the entries of every
+// container to files on the host, rotated by size, and serving them back to
+// docker logs.
+//
+// The options of the driver, set with --log-opt, are:
+//
+//	max-size  size of a file before it is rotated, e.g. 10m, unlimited by default
+//	max-file  number of files kept, rotated ones included, 1 by default
+//	compress  compress rotated files with gzip, false by default
+//
+// Files are stored in a directory per container, named after its ID, and
+// hold the entries the way the daemon sends them, see logging.EntryEncoder.
+package filelog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/logging"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+const (
+	// logName is the name of the file being written, rotated files are
+	// suffixed with their index, starting with the most recent one.
+	logName = "container.log"
+
+	maxSizeOpt  = "max-size"
+	maxFileOpt  = "max-file"
+	compressOpt = "compress"
+)
+
+// options are the rotation options of a container.
+type options struct {
+	maxSize  int64 // 0 for unlimited
+	maxFile  int
+	compress bool
+}
+
+func parseOptions(cfg map[string]string) (options, error) {
+	opts := options{maxFile: 1}
+	for k, v := range cfg {
+		var err error
+		switch k {
+		case maxSizeOpt:
+			opts.maxSize, err = parseSize(v)
+		case maxFileOpt:
+			opts.maxFile, err = strconv.Atoi(v)
+			if err == nil && opts.maxFile < 1 {
+				err = errors.New("at least one file is required")
+			}
+		case compressOpt:
+			opts.compress, err = strconv.ParseBool(v)
+		case "mode", "max-buffer-size":
+			// applied by the daemon to every log driver
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return options{}, fmt.Errorf("invalid log option %s=%q: %v", k, v, err)
+		}
+	}
+	if opts.compress && opts.maxFile == 1 {
+		return options{}, fmt.Errorf("log option %s requires %s to be greater than 1", compressOpt, maxFileOpt)
+	}
+	return opts, nil
+}
+
+// parseSize parses a size in bytes with an optional k, m or g suffix, as
+// powers of 1024. -1 stands for unlimited.
+func parseSize(s string) (int64, error) {
+	if s == "-1" {
+		return 0, nil
+	}
+	unit := int64(1)
+	num := strings.TrimSuffix(strings.ToLower(s), "b")
+	switch {
+	case strings.HasSuffix(num, "k"):
+		unit = 1 << 10
+	case strings.HasSuffix(num, "m"):
+		unit = 1 << 20
+	case strings.HasSuffix(num, "g"):
+		unit = 1 << 30
+	}
+	if unit > 1 {
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("not a positive size")
+	}
+	return n * unit, nil
+}
+
+// Driver is a logging.Driver storing the entries of every container in
+// files under its root directory.
+type Driver struct {
+	root string
+
+	mu    sync.Mutex
+	files map[string]*logFile // active files, by container ID
+}
+
+// NewDriver creates a Driver storing logs under root.
+func NewDriver(root string) (*Driver, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	return &Driver{root: root, files: make(map[string]*logFile)}, nil
+}
+
+// StartLogging opens the log file of the container of req.
+func (d *Driver) StartLogging(req *logging.StartLoggingRequest) (logging.Logger, error) {
+	id := req.Info.ContainerID
+	if id == "" || filepath.Base(id) != id || id == "." || id == ".." {
+		return nil, sdk.InvalidArgument(fmt.Errorf("invalid container ID %q", id))
+	}
+	opts, err := parseOptions(req.Info.Config)
+	if err != nil {
+		return nil, sdk.InvalidArgument(err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.files[id]; ok {
+		return nil, sdk.Conflict(fmt.Errorf("container %s is already logging", id))
+	}
+	lf, err := openLogFile(filepath.Join(d.root, id), opts)
+	if err != nil {
+		return nil, err
+	}
+	d.files[id] = lf
+	return &logger{d: d, id: id, lf: lf}, nil
+}
+
+// Capabilities reports that the driver serves docker logs.
+func (d *Driver) Capabilities() *logging.CapabilitiesResponse {
+	return &logging.CapabilitiesResponse{Cap: logging.Capability{ReadLogs: true}}
+}
+
+// ReadLogs streams the entries of the container of req, following the file
+// being written when the container is running.
+func (d *Driver) ReadLogs(req *logging.ReadLogsRequest) (io.ReadCloser, error) {
+	id := req.Info.ContainerID
+	d.mu.Lock()
+	lf, ok := d.files[id]
+	d.mu.Unlock()
+	if !ok {
+		dir := filepath.Join(d.root, filepath.Base(id))
+		if _, err := os.Stat(filepath.Join(dir, logName)); err != nil {
+			if os.IsNotExist(err) {
+				return nil, sdk.NotFound(fmt.Errorf("no logs for container %s", id))
+			}
+			return nil, err
+		}
+		// logs of a stopped container, which won't be written anymore
+		lf = &logFile{dir: dir, closed: true, notify: make(chan struct{})}
+	}
+	return lf.read(req.Config)
+}
+
+// logger writes the entries of a container to its log file.
+type logger struct {
+	d  *Driver
+	id string
+	lf *logFile
+}
+
+func (l *logger) Log(entry *logging.LogEntry) error {
+	return l.lf.write(entry)
+}
+
+func (l *logger) Close() error {
+	l.d.mu.Lock()
+	delete(l.d.files, l.id)
+	l.d.mu.Unlock()
+	return l.lf.close()
+}
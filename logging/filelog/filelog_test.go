@@ -0,0 +1,168 @@
+package filelog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/docker/go-plugins-helpers/logging"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+func startLogging(t *testing.T, d *Driver, id string, cfg map[string]string) logging.Logger {
+	l, err := d.StartLogging(&logging.StartLoggingRequest{Info: logging.Info{ContainerID: id, Config: cfg}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func logLine(t *testing.T, l logging.Logger, i int) {
+	if err := l.Log(&logging.LogEntry{Source: "stdout", Line: []byte(fmt.Sprint(i))}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLogs(t *testing.T, d *Driver, id string, cfg logging.ReadConfig) io.ReadCloser {
+	rc, err := d.ReadLogs(&logging.ReadLogsRequest{Info: logging.Info{ContainerID: id}, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc
+}
+
+func readLines(t *testing.T, r io.Reader) string {
+	var lines []string
+	dec := logging.NewEntryDecoder(r)
+	for {
+		var entry logging.LogEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return strings.Join(lines, ",")
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(entry.Line))
+	}
+}
+
+func TestRotation(t *testing.T) {
+	d, err := NewDriver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// entries take 15 bytes, two of them fit in a file
+	l := startLogging(t, d, "c1", map[string]string{"max-size": "40", "max-file": "3", "compress": "true"})
+	for i := 0; i < 10; i++ {
+		logLine(t, l, i)
+	}
+
+	files, err := filepath.Glob(filepath.Join(d.root, "c1", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	sort.Strings(files)
+	if got := strings.Join(files, ","); got != "container.log,container.log.1.gz,container.log.2.gz" {
+		t.Fatalf("unexpected files %s", got)
+	}
+
+	rc := readLogs(t, d, "c1", logging.ReadConfig{Tail: -1})
+	if got := readLines(t, rc); got != "4,5,6,7,8,9" {
+		t.Fatalf("expected entries 4 to 9, got %s", got)
+	}
+	rc.Close()
+
+	// logs stay readable once the container stopped
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rc = readLogs(t, d, "c1", logging.ReadConfig{Tail: 3, Follow: true})
+	if got := readLines(t, rc); got != "7,8,9" {
+		t.Fatalf("expected entries 7 to 9, got %s", got)
+	}
+	rc.Close()
+
+	if _, err := d.ReadLogs(&logging.ReadLogsRequest{Info: logging.Info{ContainerID: "c2"}}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found reading unknown container, got %v", err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	d, err := NewDriver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := startLogging(t, d, "c1", map[string]string{"max-size": "40", "max-file": "2"})
+	logLine(t, l, 0)
+
+	rc := readLogs(t, d, "c1", logging.ReadConfig{Tail: -1, Follow: true})
+	dec := logging.NewEntryDecoder(rc)
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			logLine(t, l, i)
+		}
+		var entry logging.LogEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if string(entry.Line) != fmt.Sprint(i) {
+			t.Fatalf("expected entry %d, got %s", i, entry.Line)
+		}
+	}
+
+	// following ends with the container
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLines(t, rc); got != "" {
+		t.Fatalf("expected no more entries, got %s", got)
+	}
+	rc.Close()
+
+	// closing a stream stops following
+	l = startLogging(t, d, "c1", nil)
+	rc = readLogs(t, d, "c1", logging.ReadConfig{Tail: 1, Follow: true})
+	dec = logging.NewEntryDecoder(rc)
+	var entry logging.LogEntry
+	if err := dec.Decode(&entry); err != nil || string(entry.Line) != "9" {
+		t.Fatalf("expected entry 9, got %s: %v", entry.Line, err)
+	}
+	rc.Close()
+	logLine(t, l, 10)
+	l.Close()
+}
+
+func TestOptions(t *testing.T) {
+	d, err := NewDriver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range []map[string]string{
+		{"max-size": "ten"},
+		{"max-file": "0"},
+		{"compress": "true"},
+		{"max-age": "1h"},
+	} {
+		_, err := d.StartLogging(&logging.StartLoggingRequest{Info: logging.Info{ContainerID: "c1", Config: cfg}})
+		if !sdk.IsInvalidArgument(err) {
+			t.Fatalf("%v: expected an invalid argument, got %v", cfg, err)
+		}
+	}
+	if _, err := d.StartLogging(&logging.StartLoggingRequest{Info: logging.Info{ContainerID: "../c1"}}); !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument for a path as ID, got %v", err)
+	}
+
+	size, err := parseSize("10m")
+	if err != nil || size != 10<<20 {
+		t.Fatalf("expected 10m to parse as %d, got %d: %v", 10<<20, size, err)
+	}
+	if _, err := os.Stat(filepath.Join(d.root, "c1")); !os.IsNotExist(err) {
+		t.Fatalf("expected no files for rejected options, got %v", err)
+	}
+}
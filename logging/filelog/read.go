@@ -0,0 +1,223 @@
+package filelog
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/logging"
+)
+
+// maxOffset stands for the end of files no longer written.
+const maxOffset = 1<<63 - 1
+
+// read returns the stream of the entries of the files of lf selected by cfg.
+func (lf *logFile) read(cfg logging.ReadConfig) (io.ReadCloser, error) {
+	// open every file at once, so that the set is consistent and none of
+	// them is rotated away while it is read
+	lf.mu.Lock()
+	rotated, err := lf.openRotated()
+	if err != nil {
+		lf.mu.Unlock()
+		return nil, err
+	}
+	current, err := os.Open(filepath.Join(lf.dir, logName))
+	if err != nil {
+		lf.mu.Unlock()
+		closeAll(rotated)
+		return nil, err
+	}
+	size, gen := lf.size, lf.gen
+	if lf.f == nil {
+		// files of a stopped container are complete
+		size = maxOffset
+	}
+	follow := cfg.Follow && !lf.closed
+	lf.mu.Unlock()
+
+	history := func(yield func(*logging.LogEntry) bool) {
+		defer closeAll(rotated)
+		if !follow {
+			defer current.Close()
+		}
+		for _, r := range rotated {
+			if _, ok := readEntries(r, yield); !ok {
+				return
+			}
+		}
+		readEntries(io.NewSectionReader(current, 0, size), yield)
+	}
+	if !follow {
+		return logging.NewEntryStream(cfg, history, nil), nil
+	}
+
+	live := make(chan *logging.LogEntry)
+	stop := make(chan struct{})
+	go lf.follow(current, size, gen, live, stop)
+	return &followStream{ReadCloser: logging.NewEntryStream(cfg, history, live), stop: stop}, nil
+}
+
+// openRotated opens the rotated files of lf, oldest first, whatever the
+// options they were rotated with. It must be called with mu held.
+func (lf *logFile) openRotated() ([]io.ReadCloser, error) {
+	names, err := filepath.Glob(filepath.Join(lf.dir, logName+".*"))
+	if err != nil {
+		return nil, err
+	}
+	index := func(name string) int {
+		s := strings.TrimPrefix(filepath.Base(name), logName+".")
+		i, err := strconv.Atoi(strings.TrimSuffix(s, ".gz"))
+		if err != nil {
+			return -1
+		}
+		return i
+	}
+	sort.Slice(names, func(i, j int) bool { return index(names[i]) > index(names[j]) })
+
+	var files []io.ReadCloser
+	for _, name := range names {
+		if index(name) < 1 {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll(files)
+			return nil, err
+		}
+		if !strings.HasSuffix(name, ".gz") {
+			files = append(files, f)
+			continue
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			closeAll(files)
+			return nil, err
+		}
+		files = append(files, &gzipFile{zr, f})
+	}
+	return files, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+func closeAll(files []io.ReadCloser) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// readEntries yields the entries read from r, until it ends or yield returns
+// false. It returns the bytes read and whether to carry on.
+func readEntries(r io.Reader, yield func(*logging.LogEntry) bool) (int64, bool) {
+	cr := &countingReader{r: r}
+	dec := logging.NewEntryDecoder(cr)
+	var n int64
+	for {
+		entry := &logging.LogEntry{}
+		if err := dec.Decode(entry); err != nil {
+			if err != io.EOF {
+				log.Printf("filelog: reading log file: %v", err)
+			}
+			return n, true
+		}
+		n = cr.n
+		if !yield(entry) {
+			return n, false
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// follow sends to live the entries written to f from offset pos on, then to
+// the files replacing it on rotation, until the container stops or stop is
+// closed. When the file is rotated several times between two reads, the
+// entries of the intermediate files are skipped.
+func (lf *logFile) follow(f *os.File, pos int64, gen int, live chan<- *logging.LogEntry, stop <-chan struct{}) {
+	defer close(live)
+	defer func() { f.Close() }()
+
+	send := func(entry *logging.LogEntry) bool {
+		select {
+		case live <- entry:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	for {
+		lf.mu.Lock()
+		size, closed, notify := lf.size, lf.closed, lf.notify
+		rotated := lf.gen != gen
+		lf.mu.Unlock()
+		if rotated {
+			// f is complete, read it to its end
+			size = maxOffset
+		}
+
+		n, ok := readEntries(io.NewSectionReader(f, pos, size-pos), send)
+		pos += n
+		if !ok {
+			return
+		}
+
+		if rotated {
+			f.Close()
+			lf.mu.Lock()
+			var err error
+			f, err = os.Open(filepath.Join(lf.dir, logName))
+			gen, pos = lf.gen, 0
+			lf.mu.Unlock()
+			if err != nil {
+				log.Printf("filelog: following rotated log file: %v", err)
+				f = nil
+				return
+			}
+			continue
+		}
+		if closed {
+			return
+		}
+		select {
+		case <-notify:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// followStream stops following the log files when it is closed.
+type followStream struct {
+	io.ReadCloser
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (s *followStream) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return s.ReadCloser.Close()
+}
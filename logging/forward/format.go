@@ -0,0 +1,193 @@
+package forward
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/logging"
+)
+
+// formatter encodes entries as messages of a log format.
+type formatter interface {
+	format(entry *logging.LogEntry) ([]byte, error)
+	// delimiter ends messages on streams, nil for octet counting framing.
+	delimiter() []byte
+}
+
+// frame returns the writes sending the message of entry over network: a
+// single one on streams, one per datagram on UDP.
+func frame(network string, f formatter, entry *logging.LogEntry) ([][]byte, error) {
+	msg, err := f.format(entry)
+	if err != nil {
+		return nil, err
+	}
+	if network == "udp" {
+		if _, ok := f.(*gelf); ok {
+			return chunkGELF(msg)
+		}
+		return [][]byte{msg}, nil
+	}
+	if d := f.delimiter(); d != nil {
+		return [][]byte{append(msg, d...)}, nil
+	}
+	// octet counting, RFC 6587
+	return [][]byte{append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)}, nil
+}
+
+func entryTime(entry *logging.LogEntry) time.Time {
+	if entry.TimeNano == 0 {
+		return time.Now()
+	}
+	return entry.Time()
+}
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslog severities
+const (
+	severityError = 3
+	severityInfo  = 6
+)
+
+func severity(entry *logging.LogEntry) int {
+	if entry.Source == "stderr" {
+		return severityError
+	}
+	return severityInfo
+}
+
+// syslog formats RFC 5424 messages.
+type syslog struct {
+	hostname string
+	appName  string
+	facility int
+}
+
+func newSyslog(hostname, tag string, facility int) *syslog {
+	return &syslog{hostname: syslogName(hostname, 255), appName: syslogName(tag, 48), facility: facility}
+}
+
+// syslogName makes s a valid header field of at most n characters: printable
+// ASCII without spaces.
+func syslogName(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > n {
+		s = s[:n]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (s *syslog) format(entry *logging.LogEntry) ([]byte, error) {
+	pri := s.facility*8 + severity(entry)
+	ts := entryTime(entry).UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	header := fmt.Sprintf("<%d>1 %s %s %s - - - ", pri, ts, s.hostname, s.appName)
+	return append([]byte(header), entry.Line...), nil
+}
+
+func (s *syslog) delimiter() []byte {
+	return nil
+}
+
+// gelf formats GELF 1.1 messages.
+type gelf struct {
+	hostname string
+	tag      string
+	info     *logging.Info
+}
+
+func newGELF(hostname, tag string, info *logging.Info) *gelf {
+	return &gelf{hostname: hostname, tag: tag, info: info}
+}
+
+type gelfMessage struct {
+	Version       string  `json:"version"`
+	Host          string  `json:"host"`
+	ShortMessage  string  `json:"short_message"`
+	Timestamp     float64 `json:"timestamp"`
+	Level         int     `json:"level"`
+	ContainerID   string  `json:"_container_id"`
+	ContainerName string  `json:"_container_name"`
+	ImageID       string  `json:"_image_id"`
+	ImageName     string  `json:"_image_name"`
+	Command       string  `json:"_command"`
+	Tag           string  `json:"_tag"`
+	Created       string  `json:"_created"`
+}
+
+func (g *gelf) format(entry *logging.LogEntry) ([]byte, error) {
+	ts := entryTime(entry)
+	return json.Marshal(&gelfMessage{
+		Version:       "1.1",
+		Host:          g.hostname,
+		ShortMessage:  string(entry.Line),
+		Timestamp:     float64(ts.UnixNano()/int64(time.Millisecond)) / 1000,
+		Level:         severity(entry),
+		ContainerID:   g.info.ContainerID,
+		ContainerName: strings.TrimPrefix(g.info.ContainerName, "/"),
+		ImageID:       g.info.ContainerImageID,
+		ImageName:     g.info.ContainerImageName,
+		Command:       strings.Join(append([]string{g.info.ContainerEntrypoint}, g.info.ContainerArgs...), " "),
+		Tag:           g.tag,
+		Created:       g.info.ContainerCreated.Format(time.RFC3339Nano),
+	})
+}
+
+func (g *gelf) delimiter() []byte {
+	return []byte{0}
+}
+
+const (
+	// gelfChunkSize is the largest GELF datagram, chunks included.
+	gelfChunkSize = 8192
+	// gelfChunkHeader is the size of the header of chunks: magic bytes,
+	// message ID, sequence number and count.
+	gelfChunkHeader = 12
+	gelfMaxChunks   = 128
+)
+
+// chunkGELF splits msg into the chunks of the GELF UDP transport, when it
+// doesn't fit in a single datagram.
+func chunkGELF(msg []byte) ([][]byte, error) {
+	if len(msg) <= gelfChunkSize {
+		return [][]byte{msg}, nil
+	}
+	size := gelfChunkSize - gelfChunkHeader
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return nil, errors.New("GELF message too large")
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, gelfChunkHeader+end-i*size)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks = append(chunks, append(chunk, msg[i*size:end]...))
+	}
+	return chunks, nil
+}
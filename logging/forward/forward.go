@@ -0,0 +1,277 @@
+// Package forward implements a log driver forwarding the entries of every
+// container to a remote syslog or GELF endpoint, over UDP or TCP.
+//
+// The options of the driver, set with --log-opt or as defaults of the
+// driver, are:
+//
+//	forward-address     endpoint to send entries to, e.g. udp://host:514 or tcp://host:12201, required
+//	forward-format      syslog, as RFC 5424 messages, or gelf, syslog by default
+//	forward-queue-size  number of messages buffered while the endpoint is slow or unreachable, 1024 by default
+//	syslog-facility     facility of syslog messages, daemon by default
+//	tag                 template identifying the container in messages, {{.ID}} by default
+//
+// The tag template is a text/template executed with TagContext.
+//
+// Messages are queued and sent in the background: the queue absorbs bursts
+// and outages of the endpoint, messages logged while it is full are dropped
+// and counted, see Driver.Stats. The connection to the endpoint is opened
+// again, with an exponential backoff, after it fails.
+package forward
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/docker/go-plugins-helpers/logging"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+const (
+	addressOpt   = "forward-address"
+	formatOpt    = "forward-format"
+	queueSizeOpt = "forward-queue-size"
+	facilityOpt  = "syslog-facility"
+	tagOpt       = "tag"
+
+	defaultQueueSize = 1024
+	defaultTag       = "{{.ID}}"
+)
+
+// options are the forwarding options of a container.
+type options struct {
+	network   string
+	address   string
+	format    string
+	queueSize int
+	facility  int
+	tag       string
+}
+
+func parseOptions(cfg map[string]string, info *logging.Info) (options, error) {
+	opts := options{format: "syslog", queueSize: defaultQueueSize, facility: facilities["daemon"]}
+	tag := defaultTag
+	for k, v := range cfg {
+		var err error
+		switch k {
+		case addressOpt:
+			opts.network, opts.address, err = parseAddress(v)
+		case formatOpt:
+			if v != "syslog" && v != "gelf" {
+				err = errors.New("not syslog or gelf")
+			}
+			opts.format = v
+		case queueSizeOpt:
+			opts.queueSize, err = strconv.Atoi(v)
+			if err == nil && opts.queueSize < 1 {
+				err = errors.New("not a positive size")
+			}
+		case facilityOpt:
+			f, ok := facilities[v]
+			if !ok {
+				err = errors.New("unknown facility")
+			}
+			opts.facility = f
+		case tagOpt:
+			tag = v
+		case "mode", "max-buffer-size":
+			// applied by the daemon to every log driver
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return options{}, fmt.Errorf("invalid log option %s=%q: %v", k, v, err)
+		}
+	}
+	if opts.address == "" {
+		return options{}, fmt.Errorf("log option %s is required", addressOpt)
+	}
+
+	var err error
+	opts.tag, err = executeTag(tag, info)
+	if err != nil {
+		return options{}, fmt.Errorf("invalid log option %s=%q: %v", tagOpt, tag, err)
+	}
+	return opts, nil
+}
+
+// parseAddress parses addresses of the form udp://host:port or
+// tcp://host:port.
+func parseAddress(s string) (network, address string, err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return "", "", errors.New("scheme must be udp or tcp")
+	}
+	if u.Port() == "" {
+		return "", "", errors.New("port is required")
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// TagContext is the data the tag template is executed with.
+type TagContext struct {
+	// ID is the short ID of the container, FullID its full ID.
+	ID          string
+	FullID      string
+	Name        string
+	ImageID     string
+	ImageFullID string
+	ImageName   string
+	DaemonName  string
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func executeTag(tag string, info *logging.Info) (string, error) {
+	tmpl, err := template.New("tag").Parse(tag)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, &TagContext{
+		ID:          shortID(info.ContainerID),
+		FullID:      info.ContainerID,
+		Name:        strings.TrimPrefix(info.ContainerName, "/"),
+		ImageID:     shortID(info.ContainerImageID),
+		ImageFullID: info.ContainerImageID,
+		ImageName:   info.ContainerImageName,
+		DaemonName:  info.DaemonName,
+	})
+	return b.String(), err
+}
+
+// Stats counts the messages of a container.
+type Stats struct {
+	// Sent is the number of messages written to the endpoint.
+	Sent uint64
+	// Dropped is the number of messages discarded because the queue was
+	// full, or because the container stopped before they could be sent.
+	Dropped uint64
+	// Failed is the number of messages that could not be encoded.
+	Failed uint64
+}
+
+// Driver is a logging.Driver forwarding the entries of every container to a
+// remote endpoint.
+type Driver struct {
+	defaults map[string]string
+	hostname string
+
+	mu      sync.Mutex
+	senders map[string]*sender // by container ID
+}
+
+// NewDriver creates a Driver. The options in defaults apply to the
+// containers that don't set them.
+func NewDriver(defaults map[string]string) *Driver {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &Driver{defaults: defaults, hostname: hostname, senders: make(map[string]*sender)}
+}
+
+// StartLogging starts forwarding the entries of the container of req.
+func (d *Driver) StartLogging(req *logging.StartLoggingRequest) (logging.Logger, error) {
+	cfg := make(map[string]string)
+	for k, v := range d.defaults {
+		cfg[k] = v
+	}
+	for k, v := range req.Info.Config {
+		cfg[k] = v
+	}
+	opts, err := parseOptions(cfg, &req.Info)
+	if err != nil {
+		return nil, sdk.InvalidArgument(err)
+	}
+
+	id := req.Info.ContainerID
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.senders[id]; ok {
+		return nil, sdk.Conflict(fmt.Errorf("container %s is already logging", id))
+	}
+	s := newSender(opts.network, opts.address, opts.queueSize)
+	d.senders[id] = s
+	go s.run()
+
+	var f formatter
+	if opts.format == "gelf" {
+		f = newGELF(d.hostname, opts.tag, &req.Info)
+	} else {
+		f = newSyslog(d.hostname, opts.tag, opts.facility)
+	}
+	return &logger{d: d, id: id, s: s, f: f, network: opts.network}, nil
+}
+
+// Capabilities reports that the driver doesn't serve docker logs.
+func (d *Driver) Capabilities() *logging.CapabilitiesResponse {
+	return &logging.CapabilitiesResponse{}
+}
+
+// ReadLogs fails, entries are not kept by the driver.
+func (d *Driver) ReadLogs(req *logging.ReadLogsRequest) (io.ReadCloser, error) {
+	return nil, errors.New("reading logs is not supported by the forward driver")
+}
+
+// Stats returns the counters of the container with the given ID, if it is
+// logging.
+func (d *Driver) Stats(id string) (Stats, bool) {
+	d.mu.Lock()
+	s, ok := d.senders[id]
+	d.mu.Unlock()
+	if !ok {
+		return Stats{}, false
+	}
+	return s.stats(), true
+}
+
+// logger forwards the entries of a container.
+type logger struct {
+	d       *Driver
+	id      string
+	s       *sender
+	f       formatter
+	network string
+}
+
+func (l *logger) Log(entry *logging.LogEntry) error {
+	if len(entry.Line) == 0 {
+		return nil
+	}
+	frames, err := frame(l.network, l.f, entry)
+	if err != nil {
+		l.s.failed.Add(1)
+		return err
+	}
+	return l.s.enqueue(frames)
+}
+
+// Close sends the messages left in the queue, for a little while, and
+// closes the connection to the endpoint. Messages logged after are refused.
+func (l *logger) Close() error {
+	l.d.mu.Lock()
+	// the container may be logging again with another sender
+	if l.d.senders[l.id] == l.s {
+		delete(l.d.senders, l.id)
+	}
+	l.d.mu.Unlock()
+	l.s.close()
+	return nil
+}
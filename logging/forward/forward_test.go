@@ -0,0 +1,247 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/logging"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+func init() {
+	minBackoff = time.Millisecond
+	maxBackoff = 10 * time.Millisecond
+	flushTimeout = 100 * time.Millisecond
+}
+
+var testInfo = logging.Info{
+	ContainerID:        "0123456789abcdef",
+	ContainerName:      "/web",
+	ContainerImageName: "nginx",
+	ContainerArgs:      []string{"-g", "daemon off;"},
+}
+
+func startLogging(t *testing.T, d *Driver, cfg map[string]string) logging.Logger {
+	info := testInfo
+	info.Config = cfg
+	l, err := d.StartLogging(&logging.StartLoggingRequest{Info: info})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func logLine(t *testing.T, l logging.Logger, source, line string) {
+	entry := &logging.LogEntry{Source: source, Line: []byte(line), TimeNano: time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC).UnixNano()}
+	if err := l.Log(entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	d := NewDriver(map[string]string{"forward-address": "udp://" + pc.LocalAddr().String()})
+	l := startLogging(t, d, map[string]string{"tag": "{{.Name}}/{{.ID}}", "syslog-facility": "local0"})
+	defer l.Close()
+	logLine(t, l, "stderr", "hello")
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<131>1 2009-11-10T23:00:00.000000Z " + d.hostname + " web/0123456789ab - - - hello"
+	if got := string(buf[:n]); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestGELFTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	d := NewDriver(nil)
+	l := startLogging(t, d, map[string]string{"forward-address": "tcp://" + ln.Addr().String(), "forward-format": "gelf"})
+	logLine(t, l, "stdout", "one")
+	logLine(t, l, "stdout", "two")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, line := range []string{"one", "two"} {
+		b, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg gelfMessage
+		if err := json.Unmarshal(b[:len(b)-1], &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.ShortMessage != line || msg.Level != severityInfo || msg.Tag != "0123456789ab" ||
+			msg.ContainerName != "web" || msg.Command != " -g daemon off;" || msg.Timestamp != 1257894000 {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+
+	// messages are counted once written
+	deadline := time.Now().Add(5 * time.Second)
+	for stats, _ := d.Stats(testInfo.ContainerID); stats.Sent != 2; stats, _ = d.Stats(testInfo.ContainerID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 messages sent, got %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	l.Close()
+	if _, ok := d.Stats(testInfo.ContainerID); ok {
+		t.Fatal("expected no stats once the container stopped")
+	}
+}
+
+func TestChunkGELF(t *testing.T) {
+	msg := bytes.Repeat([]byte("x"), 20000)
+	chunks, err := chunkGELF(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	var joined []byte
+	for i, c := range chunks {
+		if len(c) > gelfChunkSize || c[0] != 0x1e || c[1] != 0x0f || c[10] != byte(i) || c[11] != 3 {
+			t.Fatalf("invalid chunk %d header %x", i, c[:12])
+		}
+		if !bytes.Equal(c[2:10], chunks[0][2:10]) {
+			t.Fatalf("chunk %d has a different message ID", i)
+		}
+		joined = append(joined, c[12:]...)
+	}
+	if !bytes.Equal(joined, msg) {
+		t.Fatal("chunks don't hold the message")
+	}
+
+	if _, err := chunkGELF(bytes.Repeat([]byte("x"), gelfMaxChunks*gelfChunkSize)); err == nil {
+		t.Fatal("expected an error for a message too large")
+	}
+}
+
+func TestQueueAndReconnect(t *testing.T) {
+	// reserve an address nothing listens on yet
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	d := NewDriver(nil)
+	l := startLogging(t, d, map[string]string{"forward-address": "tcp://" + addr, "forward-queue-size": "2"})
+	logLine(t, l, "stdout", "line")
+	// wait for the first message to be taken from the queue
+	for len(d.senders[testInfo.ContainerID].queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		logLine(t, l, "stdout", "line")
+	}
+	// one message is being sent, two are queued
+	stats, _ := d.Stats(testInfo.ContainerID)
+	if stats.Dropped != 2 {
+		t.Fatalf("expected 2 dropped messages, got %+v", stats)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("address reused meanwhile: %v", err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for i := 0; i < 3; i++ {
+		// messages are framed with octet counting
+		var n int
+		if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasSuffix(b, []byte(" - - - line")) {
+			t.Fatalf("unexpected message %q", b)
+		}
+	}
+	l.Close()
+}
+
+func TestOptions(t *testing.T) {
+	d := NewDriver(map[string]string{"forward-address": "udp://127.0.0.1:514"})
+	for _, cfg := range []map[string]string{
+		{"forward-address": ""},
+		{"forward-address": "http://127.0.0.1:514"},
+		{"forward-address": "udp://127.0.0.1"},
+		{"forward-format": "json"},
+		{"forward-queue-size": "0"},
+		{"syslog-facility": "nope"},
+		{"tag": "{{.Unknown}}"},
+		{"labels": "app"},
+	} {
+		info := testInfo
+		info.Config = cfg
+		if _, err := d.StartLogging(&logging.StartLoggingRequest{Info: info}); !sdk.IsInvalidArgument(err) {
+			t.Fatalf("%v: expected an invalid argument, got %v", cfg, err)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	d := NewDriver(map[string]string{"forward-address": "udp://" + pc.LocalAddr().String()})
+	l := startLogging(t, d, nil)
+	entry := &logging.LogEntry{Source: "stdout", Line: []byte("line")}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for l.Log(entry) == nil {
+		}
+	}()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if err := l.Log(entry); err == nil {
+		t.Fatal("expected an error logging once closed")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,176 @@
+package forward
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// minBackoff and maxBackoff bound the delay between two connections to
+	// an endpoint that failed.
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+	// flushTimeout is how long the queue of a stopped container is sent for.
+	flushTimeout = 5 * time.Second
+)
+
+// errClosed is returned for the messages of closed senders.
+var errClosed = errors.New("logger is closed")
+
+// sender writes the queued messages of a container to its endpoint.
+type sender struct {
+	network, address string
+
+	// mu guards closed, held while enqueuing so that queue is never
+	// written to once closed.
+	mu     sync.RWMutex
+	closed bool
+
+	queue chan [][]byte
+	stop  chan struct{}
+	done  chan struct{}
+
+	sent, dropped, failed atomic.Uint64
+}
+
+func newSender(network, address string, queueSize int) *sender {
+	return &sender{
+		network: network,
+		address: address,
+		queue:   make(chan [][]byte, queueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *sender) stats() Stats {
+	return Stats{Sent: s.sent.Load(), Dropped: s.dropped.Load(), Failed: s.failed.Load()}
+}
+
+// enqueue queues the writes of a message, or drops it if the queue is full.
+// It fails once the sender is closed.
+func (s *sender) enqueue(frames [][]byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errClosed
+	}
+	select {
+	case s.queue <- frames:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+// close stops the sender once its queue is empty, or after flushTimeout.
+// Closing it again waits for it to stop.
+func (s *sender) close() {
+	s.mu.Lock()
+	closed := s.closed
+	if !closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	if closed {
+		<-s.done
+		return
+	}
+
+	select {
+	case <-s.done:
+		return
+	case <-time.After(flushTimeout):
+	}
+	close(s.stop)
+	<-s.done
+}
+
+func (s *sender) run() {
+	defer close(s.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := minBackoff
+	for frames := range s.queue {
+		for {
+			err := s.send(&conn, frames)
+			if err == nil {
+				s.sent.Add(1)
+				backoff = minBackoff
+				break
+			}
+			log.Printf("forward: sending to %s://%s: %v", s.network, s.address, err)
+			if !s.wait(backoff) {
+				s.drop(1)
+				return
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// send writes frames to *conn, connecting first when *conn is nil. *conn is
+// closed and reset when the connection fails.
+func (s *sender) send(conn *net.Conn, frames [][]byte) error {
+	if *conn == nil {
+		c, err := net.DialTimeout(s.network, s.address, dialTimeout)
+		if err != nil {
+			return err
+		}
+		*conn = c
+	}
+	if err := write(*conn, frames); err != nil {
+		(*conn).Close()
+		*conn = nil
+		return err
+	}
+	return nil
+}
+
+// wait waits for d, and reports whether the sender was not stopped meanwhile.
+func (s *sender) wait(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// drop counts the message being sent, n, and the ones left in the queue as
+// dropped.
+func (s *sender) drop(n uint64) {
+	for range s.queue {
+		n++
+	}
+	s.dropped.Add(n)
+}
+
+func write(conn net.Conn, frames [][]byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if _, err := conn.Write(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// EntrySeq yields log entries, oldest first, until yield returns false. It
+// has the shape of iter.Seq[*LogEntry]. Yielded entries may be retained and
+// must not be modified.
+type EntrySeq func(yield func(*LogEntry) bool)
+
+// entryStream is the stream of log entries returned by NewEntryStream.
+type entryStream struct {
+	pr *io.PipeReader
+	pw *io.PipeWriter
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewEntryStream returns the stream of entries ReadLogs replies with, as
+// selected by cfg. The entries stored by the driver are read from history,
+// then, when cfg follows the logs, the entries received from live are
+// streamed until live is closed or cfg.Until is reached. Either of history
+// and live may be nil.
+//
+// Closing the stream, which happens when the daemon disconnects, stops
+// reading from history and live. Drivers subscribing live to their loggers
+// should therefore unsubscribe it when history and live are no longer read,
+// rather than rely on the stream to drain it.
+func NewEntryStream(cfg ReadConfig, history EntrySeq, live <-chan *LogEntry) io.ReadCloser {
+	pr, pw := io.Pipe()
+	s := &entryStream{pr: pr, pw: pw, done: make(chan struct{})}
+	go func() {
+		pw.CloseWithError(s.send(cfg, history, live))
+	}()
+	return s
+}
+
+func (s *entryStream) Read(b []byte) (int, error) {
+	return s.pr.Read(b)
+}
+
+// Close ends the stream. It can be called concurrently with Read, which then
+// returns io.EOF.
+func (s *entryStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.pw.Close()
+	})
+	return nil
+}
+
+func (s *entryStream) send(cfg ReadConfig, history EntrySeq, live <-chan *LogEntry) error {
+	enc := NewEntryEncoder(s.pw)
+	if history != nil {
+		if err := s.sendHistory(enc, cfg, history); err != nil {
+			return err
+		}
+	}
+	if !cfg.Follow || live == nil {
+		return nil
+	}
+
+	var until <-chan time.Time
+	if !cfg.Until.IsZero() {
+		d := time.Until(cfg.Until)
+		if d <= 0 {
+			return nil
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		until = t.C
+	}
+	for {
+		select {
+		case <-s.done:
+			return nil
+		case <-until:
+			return nil
+		case entry, ok := <-live:
+			if !ok {
+				return nil
+			}
+			if before(entry, cfg) {
+				continue
+			}
+			if after(entry, cfg) {
+				return nil
+			}
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendHistory sends the stored entries selected by cfg, keeping only the
+// last cfg.Tail of them when it is not negative.
+func (s *entryStream) sendHistory(enc *EntryEncoder, cfg ReadConfig, history EntrySeq) error {
+	var err error
+	var tail []*LogEntry
+	next := 0 // oldest entry of tail once it is full
+	history(func(entry *LogEntry) bool {
+		select {
+		case <-s.done:
+			return false
+		default:
+		}
+		if before(entry, cfg) {
+			return true
+		}
+		if after(entry, cfg) {
+			return false
+		}
+		if cfg.Tail < 0 {
+			err = enc.Encode(entry)
+			return err == nil
+		}
+		if len(tail) < cfg.Tail {
+			tail = append(tail, entry)
+		} else if cfg.Tail > 0 {
+			tail[next] = entry
+			next = (next + 1) % cfg.Tail
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for i := range tail {
+		if err := enc.Encode(tail[(next+i)%len(tail)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before reports whether entry was logged before the entries selected by cfg.
+func before(entry *LogEntry, cfg ReadConfig) bool {
+	return !cfg.Since.IsZero() && entry.Time().Before(cfg.Since)
+}
+
+// after reports whether entry was logged after the entries selected by cfg.
+func after(entry *LogEntry, cfg ReadConfig) bool {
+	return !cfg.Until.IsZero() && entry.Time().After(cfg.Until)
+}
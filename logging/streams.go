@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// drainTimeout is how long the entries left in a FIFO are read for once its
+// stream stops.
+const drainTimeout = 100 * time.Millisecond
+
+// streams implements Protocol for a Driver, reading the FIFO of every
+// container logging to the driver and delivering its entries to the Logger
+// of the container.
+type streams struct {
+	driver Driver
+
+	mu     sync.Mutex
+	active map[string]*stream
+}
+
+// stream is the FIFO of a container being read.
+type stream struct {
+	file   string
+	logger Logger
+	done   chan struct{}
+
+	mu      sync.Mutex
+	f       *os.File
+	stopped bool
+}
+
+func newStreams(driver Driver) *streams {
+	return &streams{driver: driver, active: make(map[string]*stream)}
+}
+
+func (s *streams) StartLogging(req *StartLoggingRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.active[req.File]; ok {
+		return sdk.Conflict(fmt.Errorf("%s is already being logged", req.File))
+	}
+	logger, err := s.driver.StartLogging(req)
+	if err != nil {
+		return err
+	}
+	st := &stream{file: req.File, logger: logger, done: make(chan struct{})}
+	s.active[req.File] = st
+	go st.run()
+	return nil
+}
+
+func (s *streams) StopLogging(req *StopLoggingRequest) error {
+	s.mu.Lock()
+	st, ok := s.active[req.File]
+	delete(s.active, req.File)
+	s.mu.Unlock()
+	if !ok {
+		return sdk.NotFound(fmt.Errorf("%s is not being logged", req.File))
+	}
+	st.stop()
+	return st.logger.Close()
+}
+
+func (s *streams) Capabilities() *CapabilitiesResponse {
+	return s.driver.Capabilities()
+}
+
+func (s *streams) ReadLogs(req *ReadLogsRequest) (io.ReadCloser, error) {
+	return s.driver.ReadLogs(req)
+}
+
+// run opens the FIFO of the stream and delivers its entries until the
+// stream stops or the daemon closes the FIFO.
+func (st *stream) run() {
+	defer close(st.done)
+
+	// opening blocks until the daemon opens the FIFO for writing
+	f, err := os.OpenFile(st.file, os.O_RDONLY, 0)
+	if err != nil {
+		log.Printf("logging: opening %s: %v", st.file, err)
+		return
+	}
+	st.mu.Lock()
+	st.f = f
+	stopped := st.stopped
+	st.mu.Unlock()
+	defer f.Close()
+	if stopped {
+		return
+	}
+
+	dec := NewEntryDecoder(f)
+	for {
+		var entry LogEntry
+		if err := dec.Decode(&entry); err != nil {
+			if err != io.EOF && !st.isStopped() {
+				log.Printf("logging: reading %s: %v", st.file, err)
+			}
+			return
+		}
+		if err := st.logger.Log(&entry); err != nil {
+			log.Printf("logging: logging entry of %s: %v", st.file, err)
+		}
+	}
+}
+
+// stop interrupts the reading of the FIFO, once the entries it holds are
+// delivered, and waits for run to return.
+func (st *stream) stop() {
+	st.mu.Lock()
+	st.stopped = true
+	f := st.f
+	st.mu.Unlock()
+	if f != nil {
+		// the daemon stops the stream before closing the FIFO: give the
+		// entries already written a moment to be delivered
+		err := f.SetReadDeadline(time.Now().Add(drainTimeout))
+		if err != nil && !errors.Is(err, os.ErrNoDeadline) {
+			f.Close()
+		}
+		// files without deadlines are not FIFOs and are read to their end
+		<-st.done
+		return
+	}
+
+	// run may be blocked opening a FIFO the daemon never opened: open it as
+	// well, which releases run, until run notices the stream stopped
+	for {
+		if w, err := os.OpenFile(st.file, os.O_RDWR, 0); err == nil {
+			w.Close()
+		}
+		select {
+		case <-st.done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (st *stream) isStopped() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.stopped
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+//go:generate go run ../cmd/protocolgen
+
+const (
+	manifest         = `{"Implements": ["MetricsCollector"]}`
+	startMetricsPath = "/MetricsCollector.StartMetrics"
+	stopMetricsPath  = "/MetricsCollector.StopMetrics"
+)
+
+// Driver represent the interface a driver must fulfill.
+type Driver interface {
+	// Collect is called with the metric families of every scrape of the
+	// daemon metrics.
+	Collect(families []Family) error
+}
+
+// Handler forwards requests and responses between the docker daemon and the plugin.
+type Handler struct {
+	protocol Protocol
+	sdk.Handler
+}
+
+// NewHandler initializes the request handler with a driver implementation.
+// The handler scrapes the daemon metrics with a Collector, from the time the
+// daemon starts the plugin metrics until it stops them.
+func NewHandler(driver Driver) *Handler {
+	return NewProtocolHandler(NewCollector(driver))
+}
+
+// NewProtocolHandler initializes the request handler with an implementation
+// of the bare protocol, such as a Collector configured by the plugin.
+func NewProtocolHandler(protocol Protocol) *Handler {
+	h := &Handler{protocol, sdk.NewHandler(manifest)}
+	h.initMux()
+	return h
+}
@@ -0,0 +1,32 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Protocol represent the methods of the metrics collector protocol, as called by the daemon.
+type Protocol interface {
+	StartMetrics() error
+	StopMetrics() error
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(startMetricsPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := h.protocol.StartMetrics(); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(stopMetricsPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := h.protocol.StopMetrics(); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+const testMetrics = `# HELP engine_daemon_container_actions_seconds The number of seconds it takes to process each container action
+# TYPE engine_daemon_container_actions_seconds histogram
+engine_daemon_container_actions_seconds_bucket{action="start",le="0.005"} 1
+engine_daemon_container_actions_seconds_bucket{action="start",le="+Inf"} 3
+engine_daemon_container_actions_seconds_sum{action="start"} 0.25
+engine_daemon_container_actions_seconds_count{action="start"} 3
+# HELP engine_daemon_engine_info The information related to the engine and the OS it is running on
+# TYPE engine_daemon_engine_info gauge
+engine_daemon_engine_info{architecture="x86_64",kernel="6.1 \"x\"\\y\n",} 1
+# a comment
+go_goroutines 42 1257894000000
+process_cpu_seconds_total NaN
+process_max_fds +Inf
+`
+
+func TestParseText(t *testing.T) {
+	families, err := ParseText(strings.NewReader(testMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 5 {
+		t.Fatalf("expected 5 families, got %+v", families)
+	}
+
+	h := families[0]
+	if h.Name != "engine_daemon_container_actions_seconds" || h.Type != Histogram || len(h.Samples) != 4 ||
+		h.Help != "The number of seconds it takes to process each container action" {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+	if s := h.Samples[1]; s.Value != 3 || !reflect.DeepEqual(s.Labels, map[string]string{"action": "start", "le": "+Inf"}) {
+		t.Fatalf("unexpected bucket %+v", s)
+	}
+	if s := h.Samples[3]; s.Name != "engine_daemon_container_actions_seconds_count" || s.Value != 3 {
+		t.Fatalf("unexpected count %+v", s)
+	}
+
+	if s := families[1].Samples[0]; families[1].Type != Gauge || s.Labels["kernel"] != "6.1 \"x\"\\y\n" || s.Labels["architecture"] != "x86_64" {
+		t.Fatalf("unexpected gauge %+v", families[1])
+	}
+	if f := families[2]; f.Name != "go_goroutines" || f.Type != Untyped || f.Samples[0].Value != 42 ||
+		!f.Samples[0].Timestamp.Equal(time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected untyped family %+v", f)
+	}
+	if f := families[3]; !math.IsNaN(f.Samples[0].Value) {
+		t.Fatalf("expected NaN, got %+v", f)
+	}
+	if f := families[4]; !math.IsInf(f.Samples[0].Value, 1) {
+		t.Fatalf("expected +Inf, got %+v", f)
+	}
+
+	for _, text := range []string{
+		"1metric 1",
+		"metric",
+		"metric one",
+		"metric 1 2 3",
+		`metric{label=value} 1`,
+		`metric{label="value} 1`,
+		`metric{label="value" 1`,
+		`metric{la-bel="value"} 1`,
+		`metric{label="\t"} 1`,
+		"# TYPE metric enum",
+	} {
+		if _, err := ParseText(strings.NewReader(text)); err == nil {
+			t.Fatalf("%s: expected an error", text)
+		}
+	}
+}
+
+type testDriver struct {
+	families chan []Family
+}
+
+func (d *testDriver) Collect(families []Family) error {
+	d.families <- families
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "metrics.sock")
+	ml, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scrapes atomic.Int32
+	go http.Serve(ml, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		scrapes.Add(1)
+		w.Write([]byte(testMetrics))
+	}))
+	defer ml.Close()
+
+	d := &testDriver{families: make(chan []Family, 10)}
+	c := NewCollector(d)
+	c.Socket, c.Interval = socket, 10*time.Millisecond
+	h := NewProtocolHandler(c)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	client := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	if err := client.StartMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	// starting again keeps scraping on the same interval
+	if err := client.StartMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case families := <-d.families:
+			if len(families) != 5 {
+				t.Fatalf("expected 5 families, got %+v", families)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for metrics")
+		}
+	}
+
+	if err := client.StopMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	n := scrapes.Load()
+	time.Sleep(50 * time.Millisecond)
+	if scrapes.Load() != n {
+		t.Fatal("expected no scrape once stopped")
+	}
+	if err := client.StopMetrics(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindSocket(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	if p, err := FindSocket(); err == nil && p != pluginSocket && p != "/var/run/docker/metrics.sock" {
+		t.Fatalf("unexpected socket %s", p)
+	}
+
+	socket := filepath.Join(dir, "docker", "metrics.sock")
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if p, err := FindSocket(); err != nil || (p != socket && p != pluginSocket && p != "/var/run/docker/metrics.sock") {
+		t.Fatalf("expected to find %s, got %s, %v", socket, p, err)
+	}
+}
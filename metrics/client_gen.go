@@ -0,0 +1,28 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package metrics
+
+import "github.com/docker/go-plugins-helpers/sdk"
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Protocol, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Protocol = (*Client)(nil)
+
+// StartMetrics calls the StartMetrics method of the plugin.
+func (c *Client) StartMetrics() error {
+	return c.client.Call(startMetricsPath, nil, nil)
+}
+
+// StopMetrics calls the StopMetrics method of the plugin.
+func (c *Client) StopMetrics() error {
+	return c.client.Call(stopMetricsPath, nil, nil)
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInterval is the interval between two scrapes of a Collector.
+	DefaultInterval = 15 * time.Second
+	// pluginSocket is where the daemon mounts its metrics socket in the
+	// rootfs of managed plugins.
+	pluginSocket = "/run/docker/metrics.sock"
+)
+
+// socketPaths returns the paths the metrics socket is looked up at: in
+// managed plugins, then in the execution root of the daemon for plugins
+// running on the host, rootless or not.
+func socketPaths() []string {
+	paths := []string{pluginSocket, "/var/run/docker/metrics.sock"}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "docker", "metrics.sock"))
+	}
+	return paths
+}
+
+// FindSocket returns the path of the metrics socket of the daemon.
+func FindSocket() (string, error) {
+	paths := socketPaths()
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no metrics socket found in %v", paths)
+}
+
+// Collector scrapes the metrics of the daemon, in the Prometheus text format,
+// and hands them to a driver. It implements Protocol.
+type Collector struct {
+	// Socket is the path of the metrics socket, found with FindSocket
+	// when empty.
+	Socket string
+	// Interval is the interval between two scrapes, DefaultInterval when
+	// zero.
+	Interval time.Duration
+
+	driver Driver
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewCollector returns a Collector handing the metrics to driver.
+func NewCollector(driver Driver) *Collector {
+	return &Collector{driver: driver}
+}
+
+// StartMetrics starts scraping the metrics, right away and then on every
+// interval. Starting a started collector does nothing.
+func (c *Collector) StartMetrics() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return nil
+	}
+
+	socket := c.Socket
+	if socket == "" {
+		var err error
+		if socket, err = FindSocket(); err != nil {
+			return err
+		}
+	}
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel, c.done = cancel, make(chan struct{})
+	go c.run(ctx, client, interval, c.done)
+	return nil
+}
+
+// StopMetrics stops scraping the metrics, interrupting a scrape in progress.
+// Stopping a stopped collector does nothing.
+func (c *Collector) StopMetrics() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	<-c.done
+	c.cancel, c.done = nil, nil
+	return nil
+}
+
+func (c *Collector) run(ctx context.Context, client *http.Client, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	defer client.CloseIdleConnections()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		families, err := scrape(ctx, client, interval)
+		if err == nil {
+			err = c.driver.Collect(families)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("metrics: collecting daemon metrics: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// scrape reads the metrics from the socket client connects to, within
+// timeout.
+func scrape(ctx context.Context, client *http.Client, timeout time.Duration) ([]Family, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/metrics", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status " + resp.Status)
+	}
+	return ParseText(resp.Body)
+}
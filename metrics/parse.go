@@ -0,0 +1,238 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricType is the type of a metric family.
+type MetricType string
+
+// Metric types of the Prometheus text format.
+const (
+	Counter   MetricType = "counter"
+	Gauge     MetricType = "gauge"
+	Histogram MetricType = "histogram"
+	Summary   MetricType = "summary"
+	Untyped   MetricType = "untyped"
+)
+
+// Family is a metric family, with the samples of its metrics.
+type Family struct {
+	Name string
+	Help string
+	Type MetricType
+	// Samples include the _bucket, _sum and _count samples of histograms
+	// and summaries.
+	Samples []Sample
+}
+
+// Sample is a sample of a metric.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Timestamp is the time of the sample given by the endpoint, if any.
+	Timestamp time.Time
+}
+
+// ParseText parses metric families in the Prometheus text format.
+func ParseText(r io.Reader) ([]Family, error) {
+	var families []*Family
+	// family returns the last family if it is named name, or adds one
+	family := func(name string) *Family {
+		if n := len(families); n > 0 && families[n-1].Name == name {
+			return families[n-1]
+		}
+		f := &Family{Name: name, Type: Untyped}
+		families = append(families, f)
+		return f
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if err := parseComment(line, family); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			continue
+		}
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		// families are followed by their samples
+		if last := len(families) - 1; last >= 0 && belongs(families[last], sample.Name) {
+			families[last].Samples = append(families[last].Samples, sample)
+		} else {
+			f := family(sample.Name)
+			f.Samples = append(f.Samples, sample)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	res := make([]Family, len(families))
+	for i, f := range families {
+		res[i] = *f
+	}
+	return res, nil
+}
+
+// belongs reports whether the sample name is a sample of the family f.
+func belongs(f *Family, name string) bool {
+	suffix, ok := strings.CutPrefix(name, f.Name)
+	if !ok {
+		return false
+	}
+	switch f.Type {
+	case Histogram:
+		return suffix == "_bucket" || suffix == "_sum" || suffix == "_count"
+	case Summary:
+		return suffix == "_sum" || suffix == "_count"
+	}
+	return suffix == ""
+}
+
+// parseComment parses the HELP and TYPE comments, and ignores the others.
+func parseComment(line string, family func(string) *Family) error {
+	fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+	if len(fields) < 3 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+		return nil
+	}
+	if !validName(fields[1], true) {
+		return fmt.Errorf("invalid metric name %q", fields[1])
+	}
+	f := family(fields[1])
+	if fields[0] == "HELP" {
+		f.Help = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(fields[2])
+		return nil
+	}
+	switch t := MetricType(strings.TrimSpace(fields[2])); t {
+	case Counter, Gauge, Histogram, Summary, Untyped:
+		f.Type = t
+	default:
+		return fmt.Errorf("unknown metric type %q", t)
+	}
+	return nil
+}
+
+// parseSample parses a line of the form name{label="value",...} value
+// [timestamp].
+func parseSample(line string) (Sample, error) {
+	var sample Sample
+	i := strings.IndexAny(line, "{ \t")
+	if i < 0 {
+		return sample, errors.New("missing value")
+	}
+	sample.Name, line = line[:i], line[i:]
+	if !validName(sample.Name, true) {
+		return sample, fmt.Errorf("invalid metric name %q", sample.Name)
+	}
+	if line[0] == '{' {
+		var err error
+		if sample.Labels, line, err = parseLabels(line[1:]); err != nil {
+			return sample, err
+		}
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample, fmt.Errorf("invalid value %q", line)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value %q", fields[0])
+	}
+	sample.Value = v
+	if len(fields) == 2 {
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return sample, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		sample.Timestamp = time.UnixMilli(ms)
+	}
+	return sample, nil
+}
+
+// parseLabels parses the labels following an opening brace, and returns
+// the rest of the line after the closing brace.
+func parseLabels(line string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(line, "}") {
+			return labels, line[1:], nil
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, "", errors.New("unterminated labels")
+		}
+		name := strings.TrimSpace(line[:i])
+		if !validName(name, false) {
+			return nil, "", fmt.Errorf("invalid label name %q", name)
+		}
+		line = strings.TrimLeft(line[i+1:], " \t")
+		if !strings.HasPrefix(line, `"`) {
+			return nil, "", fmt.Errorf("unquoted value of label %s", name)
+		}
+
+		var value strings.Builder
+		j := 1
+		for ; j < len(line) && line[j] != '"'; j++ {
+			c := line[j]
+			if c == '\\' && j+1 < len(line) {
+				j++
+				switch line[j] {
+				case 'n':
+					c = '\n'
+				case '\\', '"':
+					c = line[j]
+				default:
+					return nil, "", fmt.Errorf("invalid escape in value of label %s", name)
+				}
+			}
+			value.WriteByte(c)
+		}
+		if j == len(line) {
+			return nil, "", fmt.Errorf("unterminated value of label %s", name)
+		}
+		labels[name] = value.String()
+
+		line = strings.TrimLeft(line[j+1:], " \t")
+		if strings.HasPrefix(line, ",") {
+			line = line[1:]
+		} else if !strings.HasPrefix(line, "}") {
+			return nil, "", errors.New("unterminated labels")
+		}
+	}
+}
+
+// validName reports whether name is a valid metric name, allowing colons,
+// or label name.
+func validName(name string, metric bool) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		case c == ':' && metric:
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -1,11 +1,13 @@
 package network
 
 import (
-	"net/http"
+	"encoding/json"
 
 	"github.com/docker/go-plugins-helpers/sdk"
 )
 
+//go:generate go run ../cmd/protocolgen
+
 const (
 	manifest = `{"Implements": ["NetworkDriver"]}`
 	// LocalScope is the correct scope response for a local scope driver
@@ -29,24 +31,6 @@ const (
 	revokeExtConnPath   = "/NetworkDriver.RevokeExternalConnectivity"
 )
 
-// Driver represent the interface a driver must fulfill.
-type Driver interface {
-	GetCapabilities() (*CapabilitiesResponse, error)
-	CreateNetwork(*CreateNetworkRequest) error
-	AllocateNetwork(*AllocateNetworkRequest) (*AllocateNetworkResponse, error)
-	DeleteNetwork(*DeleteNetworkRequest) error
-	FreeNetwork(*FreeNetworkRequest) error
-	CreateEndpoint(*CreateEndpointRequest) (*CreateEndpointResponse, error)
-	DeleteEndpoint(*DeleteEndpointRequest) error
-	EndpointInfo(*InfoRequest) (*InfoResponse, error)
-	Join(*JoinRequest) (*JoinResponse, error)
-	Leave(*LeaveRequest) error
-	DiscoverNew(*DiscoveryNotification) error
-	DiscoverDelete(*DiscoveryNotification) error
-	ProgramExternalConnectivity(*ProgramExternalConnectivityRequest) error
-	RevokeExternalConnectivity(*RevokeExternalConnectivityRequest) error
-}
-
 // CapabilitiesResponse returns whether or not this network is global or local
 type CapabilitiesResponse struct {
 	Scope             string
@@ -64,6 +48,9 @@ type AllocateNetworkRequest struct {
 
 	// IPAMData contains the address pool information for this network
 	IPv4Data, IPv6Data []IPAMData
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // AllocateNetworkResponse is the response to the AllocateNetworkRequest.
@@ -77,6 +64,9 @@ type AllocateNetworkResponse struct {
 type FreeNetworkRequest struct {
 	// The ID of the network to be freed.
 	NetworkID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // CreateNetworkRequest is sent by the daemon when a network needs to be created
@@ -85,6 +75,9 @@ type CreateNetworkRequest struct {
 	Options   map[string]interface{}
 	IPv4Data  []*IPAMData
 	IPv6Data  []*IPAMData
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // IPAMData contains IPv4 or IPv6 addressing information
@@ -98,6 +91,9 @@ type IPAMData struct {
 // DeleteNetworkRequest is sent by the daemon when a network needs to be removed
 type DeleteNetworkRequest struct {
 	NetworkID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // CreateEndpointRequest is sent by the daemon when an endpoint should be created
@@ -106,6 +102,9 @@ type CreateEndpointRequest struct {
 	EndpointID string
 	Interface  *EndpointInterface
 	Options    map[string]interface{}
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // CreateEndpointResponse is sent as a response to a CreateEndpointRequest
@@ -124,6 +123,9 @@ type EndpointInterface struct {
 type DeleteEndpointRequest struct {
 	NetworkID  string
 	EndpointID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // InterfaceName consists of the name of the interface in the global netns and
@@ -137,6 +139,9 @@ type InterfaceName struct {
 type InfoRequest struct {
 	NetworkID  string
 	EndpointID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // InfoResponse is endpoint information sent in response to an InfoRequest
@@ -150,6 +155,9 @@ type JoinRequest struct {
 	EndpointID string
 	SandboxKey string
 	Options    map[string]interface{}
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // StaticRoute contains static route information
@@ -172,6 +180,9 @@ type JoinResponse struct {
 type LeaveRequest struct {
 	NetworkID  string
 	EndpointID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // ErrorResponse is a formatted error message that libnetwork can understand
@@ -183,6 +194,9 @@ type ErrorResponse struct {
 type DiscoveryNotification struct {
 	DiscoveryType int
 	DiscoveryData interface{}
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // ProgramExternalConnectivityRequest specifies the L4 data
@@ -191,6 +205,9 @@ type ProgramExternalConnectivityRequest struct {
 	NetworkID  string
 	EndpointID string
 	Options    map[string]interface{}
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // RevokeExternalConnectivityRequest specifies the endpoint
@@ -198,6 +215,9 @@ type ProgramExternalConnectivityRequest struct {
 type RevokeExternalConnectivityRequest struct {
 	NetworkID  string
 	EndpointID string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // NewErrorResponse creates an ErrorResponse with the provided message
@@ -217,187 +237,3 @@ func NewHandler(driver Driver) *Handler {
 	h.initMux()
 	return h
 }
-
-func (h *Handler) initMux() {
-	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
-		res, err := h.driver.GetCapabilities()
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		if res == nil {
-			sdk.EncodeResponse(w, NewErrorResponse("Network driver must implement GetCapabilities"), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(createNetworkPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &CreateNetworkRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.CreateNetwork(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(allocateNetworkPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &AllocateNetworkRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.AllocateNetwork(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(deleteNetworkPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &DeleteNetworkRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.DeleteNetwork(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(freeNetworkPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &FreeNetworkRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.FreeNetwork(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(createEndpointPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &CreateEndpointRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.CreateEndpoint(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(deleteEndpointPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &DeleteEndpointRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.DeleteEndpoint(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(endpointInfoPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &InfoRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.EndpointInfo(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(joinPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &JoinRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.Join(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(leavePath, func(w http.ResponseWriter, r *http.Request) {
-		req := &LeaveRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.Leave(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(discoverNewPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &DiscoveryNotification{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.DiscoverNew(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(discoverDeletePath, func(w http.ResponseWriter, r *http.Request) {
-		req := &DiscoveryNotification{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.DiscoverDelete(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(programExtConnPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &ProgramExternalConnectivityRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.ProgramExternalConnectivity(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(revokeExtConnPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &RevokeExternalConnectivityRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.RevokeExternalConnectivity(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-}
@@ -0,0 +1,190 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package network
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Driver represent the interface a driver must fulfill.
+type Driver interface {
+	GetCapabilities() (*CapabilitiesResponse, error)
+	CreateNetwork(*CreateNetworkRequest) error
+	AllocateNetwork(*AllocateNetworkRequest) (*AllocateNetworkResponse, error)
+	DeleteNetwork(*DeleteNetworkRequest) error
+	FreeNetwork(*FreeNetworkRequest) error
+	CreateEndpoint(*CreateEndpointRequest) (*CreateEndpointResponse, error)
+	DeleteEndpoint(*DeleteEndpointRequest) error
+	EndpointInfo(*InfoRequest) (*InfoResponse, error)
+	Join(*JoinRequest) (*JoinResponse, error)
+	Leave(*LeaveRequest) error
+	DiscoverNew(*DiscoveryNotification) error
+	DiscoverDelete(*DiscoveryNotification) error
+	ProgramExternalConnectivity(*ProgramExternalConnectivityRequest) error
+	RevokeExternalConnectivity(*RevokeExternalConnectivityRequest) error
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.driver.GetCapabilities()
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		if res == nil {
+			sdk.EncodeError(w, errors.New("Network driver must implement GetCapabilities"))
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(createNetworkPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &CreateNetworkRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.CreateNetwork(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(allocateNetworkPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &AllocateNetworkRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.AllocateNetwork(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(deleteNetworkPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DeleteNetworkRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.DeleteNetwork(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(freeNetworkPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &FreeNetworkRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.FreeNetwork(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(createEndpointPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &CreateEndpointRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.CreateEndpoint(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(deleteEndpointPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DeleteEndpointRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.DeleteEndpoint(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(endpointInfoPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &InfoRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.EndpointInfo(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(joinPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &JoinRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := h.driver.Join(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, res, false)
+	})
+	h.HandleFunc(leavePath, func(w http.ResponseWriter, r *http.Request) {
+		req := &LeaveRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.Leave(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(discoverNewPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DiscoveryNotification{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.DiscoverNew(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(discoverDeletePath, func(w http.ResponseWriter, r *http.Request) {
+		req := &DiscoveryNotification{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.DiscoverDelete(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(programExtConnPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ProgramExternalConnectivityRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.ProgramExternalConnectivity(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+	h.HandleFunc(revokeExtConnPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &RevokeExternalConnectivityRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		if err := h.driver.RevokeExternalConnectivity(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+}
@@ -137,7 +137,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestActivate(t *testing.T) {
-	response, err := http.Get("http://localhost:32234/Plugin.Activate")
+	response, err := http.Post("http://localhost:32234/Plugin.Activate", sdk.DefaultContentTypeV1_1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -150,7 +150,7 @@ func TestActivate(t *testing.T) {
 }
 
 func TestCapabilitiesExchange(t *testing.T) {
-	response, err := http.Get("http://localhost:32234/NetworkDriver.GetCapabilities")
+	response, err := http.Post("http://localhost:32234/NetworkDriver.GetCapabilities", sdk.DefaultContentTypeV1_1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -163,6 +163,46 @@ func TestCapabilitiesExchange(t *testing.T) {
 	}
 }
 
+func TestMethodNotAllowed(t *testing.T) {
+	response, err := http.Get("http://localhost:32234/NetworkDriver.GetCapabilities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected %d, got %d\n", http.StatusMethodNotAllowed, response.StatusCode)
+	}
+	if ct := response.Header.Get("Content-Type"); ct != sdk.DefaultContentTypeV1_1 {
+		t.Fatalf("Expected content type %s, got %s\n", sdk.DefaultContentTypeV1_1, ct)
+	}
+	expected := `{"Err":"method GET not allowed for /NetworkDriver.GetCapabilities"}`
+	if string(body) != expected+"\n" {
+		t.Fatalf("Expected %s, got %s\n", expected+"\n", string(body))
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	response, err := http.Post("http://localhost:32234/NetworkDriver.Unknown", sdk.DefaultContentTypeV1_1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d\n", http.StatusNotFound, response.StatusCode)
+	}
+	if ct := response.Header.Get("Content-Type"); ct != sdk.DefaultContentTypeV1_1 {
+		t.Fatalf("Expected content type %s, got %s\n", sdk.DefaultContentTypeV1_1, ct)
+	}
+	expected := `{"Err":"/NetworkDriver.Unknown is not implemented by this plugin"}`
+	if string(body) != expected+"\n" {
+		t.Fatalf("Expected %s, got %s\n", expected+"\n", string(body))
+	}
+}
+
 func TestCreateNetworkSuccess(t *testing.T) {
 	request := `{"NetworkID":"d76cfa51738e8a12c5eca71ee69e9d65010a4b48eaad74adab439be7e61b9aaf","Options":{"com.docker.network.generic":{}},"IPv4Data":[{"AddressSpace":"","Gateway":"172.18.0.1/16","Pool":"172.18.0.0/16"}],"IPv6Data":[]}`
 
@@ -0,0 +1,108 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package network
+
+import "github.com/docker/go-plugins-helpers/sdk"
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Driver, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Driver = (*Client)(nil)
+
+// GetCapabilities calls the GetCapabilities method of the plugin.
+func (c *Client) GetCapabilities() (*CapabilitiesResponse, error) {
+	res := &CapabilitiesResponse{}
+	if err := c.client.Call(capabilitiesPath, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CreateNetwork calls the CreateNetwork method of the plugin.
+func (c *Client) CreateNetwork(req *CreateNetworkRequest) error {
+	return c.client.Call(createNetworkPath, req, nil)
+}
+
+// AllocateNetwork calls the AllocateNetwork method of the plugin.
+func (c *Client) AllocateNetwork(req *AllocateNetworkRequest) (*AllocateNetworkResponse, error) {
+	res := &AllocateNetworkResponse{}
+	if err := c.client.Call(allocateNetworkPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteNetwork calls the DeleteNetwork method of the plugin.
+func (c *Client) DeleteNetwork(req *DeleteNetworkRequest) error {
+	return c.client.Call(deleteNetworkPath, req, nil)
+}
+
+// FreeNetwork calls the FreeNetwork method of the plugin.
+func (c *Client) FreeNetwork(req *FreeNetworkRequest) error {
+	return c.client.Call(freeNetworkPath, req, nil)
+}
+
+// CreateEndpoint calls the CreateEndpoint method of the plugin.
+func (c *Client) CreateEndpoint(req *CreateEndpointRequest) (*CreateEndpointResponse, error) {
+	res := &CreateEndpointResponse{}
+	if err := c.client.Call(createEndpointPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteEndpoint calls the DeleteEndpoint method of the plugin.
+func (c *Client) DeleteEndpoint(req *DeleteEndpointRequest) error {
+	return c.client.Call(deleteEndpointPath, req, nil)
+}
+
+// EndpointInfo calls the EndpointInfo method of the plugin.
+func (c *Client) EndpointInfo(req *InfoRequest) (*InfoResponse, error) {
+	res := &InfoResponse{}
+	if err := c.client.Call(endpointInfoPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Join calls the Join method of the plugin.
+func (c *Client) Join(req *JoinRequest) (*JoinResponse, error) {
+	res := &JoinResponse{}
+	if err := c.client.Call(joinPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Leave calls the Leave method of the plugin.
+func (c *Client) Leave(req *LeaveRequest) error {
+	return c.client.Call(leavePath, req, nil)
+}
+
+// DiscoverNew calls the DiscoverNew method of the plugin.
+func (c *Client) DiscoverNew(req *DiscoveryNotification) error {
+	return c.client.Call(discoverNewPath, req, nil)
+}
+
+// DiscoverDelete calls the DiscoverDelete method of the plugin.
+func (c *Client) DiscoverDelete(req *DiscoveryNotification) error {
+	return c.client.Call(discoverDeletePath, req, nil)
+}
+
+// ProgramExternalConnectivity calls the ProgramExternalConnectivity method of the plugin.
+func (c *Client) ProgramExternalConnectivity(req *ProgramExternalConnectivityRequest) error {
+	return c.client.Call(programExtConnPath, req, nil)
+}
+
+// RevokeExternalConnectivity calls the RevokeExternalConnectivity method of the plugin.
+func (c *Client) RevokeExternalConnectivity(req *RevokeExternalConnectivityRequest) error {
+	return c.client.Call(revokeExtConnPath, req, nil)
+}
@@ -0,0 +1,189 @@
+// Package bundle assembles the bundle directories of managed plugins, read
+// by docker plugin create: a config.json file and the rootfs directory of
+// the plugin.
+package bundle
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// RootfsDir is the name of the rootfs directory of a bundle.
+const RootfsDir = "rootfs"
+
+// Rootfs populates the rootfs directory dir of a plugin. It can set the
+// fields of config derived from the rootfs, such as the entrypoint.
+type Rootfs func(dir string, config *sdk.PluginConfig) error
+
+// FromTar extracts the rootfs from a tar archive, compressed with gzip or
+// not, such as the one exported by docker export. Files keep their owner,
+// when building as root, and their mode, setuid, setgid and sticky bits
+// included.
+func FromTar(r io.Reader) Rootfs {
+	return func(dir string, _ *sdk.PluginConfig) error {
+		br := bufio.NewReader(r)
+		var archive io.Reader = br
+		if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			archive = zr
+		}
+		return extractRootfs(dir, archive)
+	}
+}
+
+// FromBinary makes a minimal rootfs holding the statically linked binary,
+// started as the entrypoint of the plugin. The binary is copied to
+// the first element of the entrypoint, or to the root of the rootfs when
+// the entrypoint is empty.
+func FromBinary(binary string) Rootfs {
+	return func(dir string, config *sdk.PluginConfig) error {
+		if len(config.Entrypoint) == 0 {
+			config.Entrypoint = []string{"/" + filepath.Base(binary)}
+		}
+		for _, d := range []string{"/dev", "/etc", "/proc", "/run/docker/plugins", "/sys", "/tmp"} {
+			if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(filepath.Join(dir, "tmp"), 0777|os.ModeSticky); err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+config.Entrypoint[0])))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return copyFile(dst, binary, 0755)
+	}
+}
+
+func copyFile(dst, src string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Bundle describes the bundle of a managed plugin.
+type Bundle struct {
+	Config *sdk.PluginConfig
+	Rootfs Rootfs
+	// Addr is the address the plugin passes to ServeUnix. When set, the
+	// socket of Config must be the one the plugin listens on.
+	Addr string
+}
+
+// Build creates the bundle in dir, which must not exist or be empty. Nothing
+// is left in dir when it fails.
+func (b *Bundle) Build(dir string) (err error) {
+	if b.Config == nil || b.Rootfs == nil {
+		return errors.New("bundle without config or rootfs")
+	}
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+	defer func() {
+		if err != nil {
+			removeContent(dir)
+		}
+	}()
+
+	rootfs := filepath.Join(dir, RootfsDir)
+	if err := os.Mkdir(rootfs, 0755); err != nil {
+		return err
+	}
+	if err := b.Rootfs(rootfs, b.Config); err != nil {
+		return fmt.Errorf("rootfs: %w", err)
+	}
+	if err := check(rootfs, b.Config, b.Addr); err != nil {
+		return err
+	}
+	// the copy in the rootfs tells the plugin it runs as a managed plugin
+	managed := rootfs
+	for _, d := range strings.Split(strings.TrimPrefix(path.Dir(sdk.ManagedConfigPath), "/"), "/") {
+		managed = filepath.Join(managed, d)
+		if err := os.Mkdir(managed, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+		if fi, err := os.Lstat(managed); err != nil {
+			return err
+		} else if !fi.IsDir() {
+			return fmt.Errorf("rootfs: %s is not a directory", d)
+		}
+	}
+	if err := b.Config.WriteFile(managed); err != nil {
+		return err
+	}
+	return b.Config.WriteFile(dir)
+}
+
+// Check checks the bundle in dir: its config, the socket of the plugin
+// when addr is set, and the entrypoint of its rootfs.
+func Check(dir, addr string) error {
+	config, err := sdk.ReadPluginConfig(filepath.Join(dir, sdk.PluginConfigFile))
+	if err != nil {
+		return err
+	}
+	return check(filepath.Join(dir, RootfsDir), config, addr)
+}
+
+func check(rootfs string, config *sdk.PluginConfig, addr string) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if addr != "" {
+		if err := config.CheckSocket(addr); err != nil {
+			return err
+		}
+	}
+	entrypoint := config.Entrypoint[0]
+	if !path.IsAbs(entrypoint) {
+		// relative commands are looked up in the PATH of the plugin
+		return nil
+	}
+	fi, err := os.Lstat(filepath.Join(rootfs, filepath.FromSlash(path.Clean(entrypoint))))
+	if err != nil {
+		return fmt.Errorf("entrypoint: %w", err)
+	}
+	// links may be absolute in the rootfs, they are left to the runtime
+	if fi.Mode()&os.ModeSymlink == 0 && (!fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0) {
+		return fmt.Errorf("entrypoint: %s is not an executable file", entrypoint)
+	}
+	return nil
+}
+
+func removeContent(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		os.RemoveAll(filepath.Join(dir, e.Name()))
+	}
+}
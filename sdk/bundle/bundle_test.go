@@ -0,0 +1,131 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+func testConfig() *sdk.PluginConfig {
+	c := sdk.NewHandler(`{"Implements": ["VolumeDriver"]}`).PluginConfig("test")
+	c.PropagatedMount = "/mnt/volumes"
+	return &c
+}
+
+func TestFromBinary(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "myplugin")
+	if err := os.WriteFile(binary, []byte("#!/bin/true\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "bundle")
+	config := testConfig()
+	b := &Bundle{Config: config, Rootfs: FromBinary(binary), Addr: "test"}
+	if err := b.Build(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Entrypoint) != 1 || config.Entrypoint[0] != "/myplugin" {
+		t.Fatalf("unexpected entrypoint %v", config.Entrypoint)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, RootfsDir, "myplugin")); err != nil || fi.Mode().Perm() != 0755 {
+		t.Fatalf("expected an executable entrypoint, got %v, %v", fi, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, RootfsDir, "run", "docker", "plugins")); err != nil || !fi.IsDir() {
+		t.Fatalf("expected the socket directory, got %v", err)
+	}
+
+	read, err := sdk.ReadPluginConfig(filepath.Join(dir, sdk.PluginConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Interface.Socket != "test.sock" || read.Interface.Types[0] != "docker.volumedriver/1.0" || read.PropagatedMount != "/mnt/volumes" {
+		t.Fatalf("unexpected config %+v", read)
+	}
+
+	// the rootfs holds a copy of the config, to detect the managed plugin
+	managed, err := sdk.ReadPluginConfig(filepath.Join(dir, RootfsDir, filepath.FromSlash(sdk.ManagedConfigPath)))
+	if err != nil || managed.Interface.Socket != "test.sock" {
+		t.Fatalf("expected the managed plugin config in the rootfs, got %+v, %v", managed, err)
+	}
+	if err := Check(dir, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(dir, "other"); err == nil {
+		t.Fatal("expected an error checking the socket of another address")
+	}
+	if err := b.Build(dir); err == nil {
+		t.Fatal("expected an error building in a bundle")
+	}
+}
+
+func TestFromTar(t *testing.T) {
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+	tw.WriteHeader(&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "bin/myplugin", Typeflag: tar.TypeReg, Mode: 0755, Size: 3})
+	tw.Write([]byte("elf"))
+	tw.WriteHeader(&tar.Header{Name: "bin/config", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.WriteHeader(&tar.Header{Name: "bin/su", Typeflag: tar.TypeReg, Mode: 04755})
+	tw.WriteHeader(&tar.Header{Name: "tmp/", Typeflag: tar.TypeDir, Mode: 01777})
+	tw.WriteHeader(&tar.Header{Name: "etc/.wh.keep", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.WriteHeader(&tar.Header{Name: "run/fifo", Typeflag: tar.TypeFifo, Mode: 0600})
+	tw.WriteHeader(&tar.Header{Name: "dev/null", Typeflag: tar.TypeChar, Mode: 0666, Devmajor: 1, Devminor: 3})
+	tw.Close()
+	zw.Close()
+
+	config := testConfig()
+	config.Entrypoint = []string{"/bin/myplugin", "--debug"}
+	dir := t.TempDir()
+	if err := (&Bundle{Config: config, Rootfs: FromTar(bytes.NewReader(archive.Bytes()))}).Build(dir); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, RootfsDir, "bin", "myplugin")); err != nil || string(b) != "elf" {
+		t.Fatalf("expected the extracted entrypoint, got %q, %v", b, err)
+	}
+	// a rootfs is not a layer, its modes are kept and it has no whiteouts
+	for name, mode := range map[string]os.FileMode{
+		"bin/su":       0755 | os.ModeSetuid,
+		"tmp":          0777 | os.ModeSticky | os.ModeDir,
+		"etc/.wh.keep": 0644,
+		"run/fifo":     0600 | os.ModeNamedPipe,
+	} {
+		if fi, err := os.Lstat(filepath.Join(dir, RootfsDir, filepath.FromSlash(name))); err != nil || fi.Mode() != mode {
+			t.Fatalf("%s: expected mode %v, got %v, %v", name, mode, fi, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		entrypoint string
+		addr       string
+		err        string
+	}{
+		{"/bin/missing", "", "entrypoint"},
+		{"/bin/config", "", "not an executable"},
+		{"/bin", "", "not an executable"},
+		{"/bin/myplugin", "/run/test.sock", "not in /run/docker/plugins"},
+		{"/bin/myplugin", "other", "doesn't match"},
+	} {
+		config := testConfig()
+		config.Entrypoint = []string{tc.entrypoint}
+		dir := t.TempDir()
+		err := (&Bundle{Config: config, Rootfs: FromTar(bytes.NewReader(archive.Bytes())), Addr: tc.addr}).Build(dir)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected an error containing %q, got %v", tc.entrypoint, tc.err, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Fatalf("%s: expected an empty directory after failing, got %v", tc.entrypoint, entries)
+		}
+	}
+
+	// the socket of plugins listening at an absolute path is its name
+	config.Entrypoint = []string{"/bin/myplugin"}
+	if err := config.CheckSocket("/run/docker/plugins/test.sock"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,160 @@
+package bundle
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extractRootfs extracts the tar archive of a rootfs into dir, with the
+// owners, modes and modification times of its files. Unlike layers, the
+// archive has no whiteouts. Entries can't reach outside of dir, through
+// their path or symbolic links.
+func extractRootfs(dir string, r io.Reader) error {
+	var dirs []*tar.Header
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		if err := mkdirParents(dir, name); err != nil {
+			return err
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		// later entries replace earlier ones, but directories are merged
+		if fi, err := os.Lstat(p); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(p, 0700); err != nil && !os.IsExist(err) {
+				return err
+			}
+			// the mode and times of directories are set once their content
+			// is extracted
+			hdr.Name = p
+			dirs = append(dirs, hdr)
+			continue
+		case tar.TypeReg:
+			if err := extractFile(p, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// links are extracted as is, they are resolved in the rootfs
+			if err := os.Symlink(hdr.Linkname, p); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			target := path.Clean("/" + hdr.Linkname)
+			if err := mkdirParents(dir, target); err != nil {
+				return err
+			}
+			if err := os.Link(filepath.Join(dir, filepath.FromSlash(target)), p); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			created, err := mknod(p, hdr)
+			if err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			if !created {
+				continue
+			}
+		default:
+			return fmt.Errorf("unsupported entry %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+
+		if err := chown(p, hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			continue
+		}
+		if err := os.Chmod(p, fileMode(hdr)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(p, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		hdr := dirs[i]
+		if err := chown(hdr.Name, hdr); err != nil {
+			return err
+		}
+		if err := os.Chmod(hdr.Name, fileMode(hdr)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(hdr.Name, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mkdirParents creates the missing parents of the file at name, in the
+// form /a/b, under dir. Parents which are symbolic links are an error.
+func mkdirParents(dir, name string) error {
+	p := dir
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for _, part := range parts[:len(parts)-1] {
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := os.Mkdir(p, 0755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !fi.IsDir():
+			return fmt.Errorf("%s: parent %s is not a directory", name, part)
+		}
+	}
+	return nil
+}
+
+func extractFile(p string, r io.Reader) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// fileMode returns the permissions of the file of hdr, with its setuid,
+// setgid and sticky bits.
+func fileMode(hdr *tar.Header) os.FileMode {
+	return hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// chown sets the owner of the file at p, when running as root.
+func chown(p string, hdr *tar.Header) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(p, hdr.Uid, hdr.Gid)
+}
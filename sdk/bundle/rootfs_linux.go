@@ -0,0 +1,32 @@
+package bundle
+
+import (
+	"archive/tar"
+	"errors"
+	"syscall"
+)
+
+// mknod creates the device or named pipe of hdr at p. Devices are skipped
+// when the process isn't allowed to create them, the runtime of the plugin
+// populates /dev.
+func mknod(p string, hdr *tar.Header) (bool, error) {
+	mode := uint32(hdr.Mode & 07777)
+	switch hdr.Typeflag {
+	case tar.TypeChar:
+		mode |= syscall.S_IFCHR
+	case tar.TypeBlock:
+		mode |= syscall.S_IFBLK
+	default:
+		mode |= syscall.S_IFIFO
+	}
+	err := syscall.Mknod(p, mode, mkdev(hdr.Devmajor, hdr.Devminor))
+	if hdr.Typeflag != tar.TypeFifo && (errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES)) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// mkdev returns the Linux device number of major and minor.
+func mkdev(major, minor int64) int {
+	return int((minor & 0xff) | (major&0xfff)<<8 | (minor&^0xff)<<12 | (major&^0xfff)<<32)
+}
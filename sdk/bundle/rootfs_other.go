@@ -0,0 +1,15 @@
+//go:build !linux
+
+package bundle
+
+import (
+	"archive/tar"
+	"fmt"
+	"runtime"
+)
+
+// mknod fails, plugins bundled on other systems than Linux can't have
+// devices or named pipes in their rootfs.
+func mknod(p string, hdr *tar.Header) (bool, error) {
+	return false, fmt.Errorf("device files are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,159 @@
+package sdk
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/go-connections/sockets"
+)
+
+// maxErrorSize is the largest error body read from a plugin response.
+const maxErrorSize = 64 * 1024
+
+// Client sends requests to a plugin using the plugin protocol.
+type Client struct {
+	http *http.Client
+	base string
+}
+
+// NewClient creates a Client for the plugin listening on addr, for example
+// unix:///run/docker/plugins/foo.sock or tcp://localhost:8080.
+func NewClient(addr string, tlsConfig *tls.Config) (*Client, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	socket := u.Host
+	if socket == "" {
+		// local socket addresses only have a path
+		socket = u.Path
+	}
+
+	tr := &http.Transport{TLSClientConfig: tlsConfig}
+	if err := sockets.ConfigureTransport(tr, u.Scheme, socket); err != nil {
+		return nil, err
+	}
+
+	base := "http://plugin"
+	if u.Scheme == "tcp" || u.Scheme == "http" || u.Scheme == "https" {
+		scheme := "http"
+		if tlsConfig != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + u.Host
+	}
+	return &Client{http: &http.Client{Transport: tr}, base: base}, nil
+}
+
+// NewClientWithTransport creates a Client sending its requests through t,
+// whatever their destination.
+func NewClientWithTransport(t http.RoundTripper) *Client {
+	return &Client{http: &http.Client{Transport: t}, base: "http://plugin"}
+}
+
+// Call sends req as the JSON body of the plugin method at path and decodes
+// the response into res. A nil req sends an empty body, a nil res discards
+// the response. Errors reported by the plugin are returned with their
+// message unchanged and typed after the status code of the response.
+func (c *Client) Call(path string, req, res interface{}) error {
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	return c.Send(path, body, res)
+}
+
+// Send sends body as is to the plugin method at path, which may hold query
+// parameters, and decodes the response into res like Call.
+func (c *Client) Send(path string, body io.Reader, res interface{}) error {
+	r, err := c.do(path, body)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if res == nil {
+		_, err = io.Copy(io.Discard, r.Body)
+		return err
+	}
+	return json.NewDecoder(r.Body).Decode(res)
+}
+
+// Stream sends req as the JSON body of the plugin method at path and returns
+// the body of the response, for methods streaming their response. The caller
+// must close it.
+func (c *Client) Stream(path string, req interface{}) (io.ReadCloser, error) {
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	r, err := c.do(path, body)
+	if err != nil {
+		return nil, err
+	}
+	return r.Body, nil
+}
+
+func (c *Client) do(path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", DefaultContentTypeV1_1)
+	req.Header.Set("Content-Type", DefaultContentTypeV1_1)
+
+	r, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		defer r.Body.Close()
+		return nil, readError(r)
+	}
+	return r, nil
+}
+
+// readError converts an error response of a plugin back into the typed
+// error that produced it, see StatusCode.
+func readError(r *http.Response) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxErrorSize))
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSpace(string(b))
+	var res ErrorResponse
+	if json.Unmarshal(b, &res) == nil && res.Err != "" {
+		msg = res.Err
+	}
+	if msg == "" {
+		msg = http.StatusText(r.StatusCode)
+	}
+
+	err = errors.New(msg)
+	switch r.StatusCode {
+	case http.StatusNotFound:
+		return NotFound(err)
+	case http.StatusConflict:
+		return Conflict(err)
+	case http.StatusBadRequest:
+		return InvalidArgument(err)
+	case http.StatusServiceUnavailable:
+		return Unavailable(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,131 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DecodeMode controls how DecodeRequest treats the fields of a request body
+// that are not part of the structure it is decoded into.
+type DecodeMode int
+
+const (
+	// DecodeLenient ignores unknown fields. This is the default.
+	DecodeLenient DecodeMode = iota
+	// DecodeCollect stores unknown fields in the Unknown field of the
+	// request structure, a map[string]json.RawMessage, and logs their
+	// names. It lets drivers use fields sent by newer daemons before this
+	// library knows them.
+	DecodeCollect
+	// DecodeStrict rejects requests containing unknown fields with a bad
+	// request error. It is meant for tests.
+	DecodeStrict
+)
+
+type decodeModeKey struct{}
+
+func withDecodeMode(ctx context.Context, mode DecodeMode) context.Context {
+	return context.WithValue(ctx, decodeModeKey{}, mode)
+}
+
+func decodeModeFromContext(ctx context.Context) DecodeMode {
+	mode, _ := ctx.Value(decodeModeKey{}).(DecodeMode)
+	return mode
+}
+
+// decode decodes body into req, and returns the fields unknown to req when
+// collecting them, also stored in its Unknown field.
+func decode(body io.Reader, req interface{}, mode DecodeMode) (map[string]json.RawMessage, error) {
+	switch mode {
+	case DecodeStrict:
+		d := json.NewDecoder(body)
+		d.DisallowUnknownFields()
+		return nil, d.Decode(req)
+	case DecodeCollect:
+		var buf bytes.Buffer
+		if err := json.NewDecoder(io.TeeReader(body, &buf)).Decode(req); err != nil {
+			return nil, err
+		}
+		return collectUnknownFields(buf.Bytes(), req), nil
+	default:
+		return nil, json.NewDecoder(body).Decode(req)
+	}
+}
+
+// collectUnknownFields stores the members of the JSON object in data that
+// don't map to a field of req into its Unknown map, and returns them.
+func collectUnknownFields(data []byte, req interface{}) map[string]json.RawMessage {
+	v := reflect.ValueOf(req)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	known := jsonFieldNames(v.Type())
+
+	var extra map[string]json.RawMessage
+	for name, value := range fields {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[name] = value
+	}
+	if f := v.FieldByName("Unknown"); extra != nil && f.IsValid() && f.CanSet() && f.Type() == reflect.TypeOf(extra) {
+		f.Set(reflect.ValueOf(extra))
+	}
+	return extra
+}
+
+// jsonFieldNames returns the lower cased names encoding/json matches against
+// the fields of t, including the ones promoted from embedded structures.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for n := range jsonFieldNames(ft) {
+				names[n] = true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
+
+func logUnknownFields(path string, extra map[string]json.RawMessage) {
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	log.Printf("plugin: unknown fields in %s request: %s", path, strings.Join(names, ", "))
+}
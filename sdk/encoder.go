@@ -1,6 +1,7 @@
 package sdk
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,9 +12,18 @@ import (
 const DefaultContentTypeV1_1 = "application/vnd.docker.plugins.v1.1+json"
 
 // DecodeRequest decodes an http request into a given structure.
+// When the body can't be decoded, a bad request error is written to the
+// response and returned. Fields unknown to req are handled according to the
+// DecodeMode of the Handler serving the request.
 func DecodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) (err error) {
-	if err = json.NewDecoder(r.Body).Decode(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	unknown, err := decode(r.Body, req, decodeModeFromContext(r.Context()))
+	if err != nil {
+		err = InvalidArgument(err)
+		EncodeError(w, err)
+		return
+	}
+	if len(unknown) > 0 {
+		logUnknownFields(r.URL.Path, unknown)
 	}
 	return
 }
@@ -27,11 +37,55 @@ func EncodeResponse(w http.ResponseWriter, res interface{}, err bool) {
 	json.NewEncoder(w).Encode(res)
 }
 
-// StreamResponse streams a response object to the client
+// EncodeError encodes an error into an http response. The status code is
+// chosen from the type of err, see StatusCode, and the body carries the
+// error message in the format the docker daemon understands.
+func EncodeError(w http.ResponseWriter, err error) {
+	encodeError(w, StatusCode(err), err.Error())
+}
+
+func encodeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", DefaultContentTypeV1_1)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&ErrorResponse{Err: msg})
+}
+
+// StreamResponse streams a response object to the client. Every chunk read
+// from data is flushed to the client as soon as it is written.
 func StreamResponse(w http.ResponseWriter, data io.ReadCloser) {
 	w.Header().Set("Content-Type", DefaultContentTypeV1_1)
-	if _, err := copyBuf(w, data); err != nil {
+	if _, err := copyBuf(flushWriter(w), data); err != nil {
 		fmt.Printf("ERROR in stream: %v\n", err)
 	}
 	data.Close()
 }
+
+// StreamResponseContext streams a response object to the client like
+// StreamResponse, closing data as soon as ctx is done, typically when the
+// client of the request disconnects. data must support being closed while
+// it is read, which should end the stream.
+func StreamResponseContext(ctx context.Context, w http.ResponseWriter, data io.ReadCloser) {
+	stop := context.AfterFunc(ctx, func() { data.Close() })
+	defer stop()
+	StreamResponse(w, data)
+}
+
+type flusher struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func (f *flusher) Write(b []byte) (int, error) {
+	n, err := f.w.Write(b)
+	f.f.Flush()
+	return n, err
+}
+
+// flushWriter returns a writer flushing w after every write, when w
+// supports it.
+func flushWriter(w http.ResponseWriter) io.Writer {
+	if f, ok := w.(http.Flusher); ok {
+		return &flusher{w: w, f: f}
+	}
+	return w
+}
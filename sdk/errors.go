@@ -0,0 +1,134 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorResponse is the error body understood by the docker daemon. The daemon
+// reports the Err field to the user as is.
+type ErrorResponse struct {
+	Err string
+}
+
+// ErrNotFound signals that the requested object doesn't exist.
+type ErrNotFound interface {
+	NotFound()
+}
+
+// ErrConflict signals that the request conflicts with the current state of
+// the object, for example removing a volume that is still in use.
+type ErrConflict interface {
+	Conflict()
+}
+
+// ErrInvalidArgument signals that the request contains invalid parameters.
+type ErrInvalidArgument interface {
+	InvalidArgument()
+}
+
+// ErrUnavailable signals that the plugin can't serve the request right now.
+type ErrUnavailable interface {
+	Unavailable()
+}
+
+// The method sets of these interfaces match the ones of the
+// github.com/docker/docker/errdefs package, so errors coming from Docker
+// Engine code are classified the same way.
+
+type errNotFound struct{ error }
+
+func (errNotFound) NotFound()       {}
+func (e errNotFound) Unwrap() error { return e.error }
+
+type errConflict struct{ error }
+
+func (errConflict) Conflict()       {}
+func (e errConflict) Unwrap() error { return e.error }
+
+type errInvalidArgument struct{ error }
+
+func (errInvalidArgument) InvalidArgument() {}
+func (e errInvalidArgument) Unwrap() error  { return e.error }
+
+type errUnavailable struct{ error }
+
+func (errUnavailable) Unavailable()    {}
+func (e errUnavailable) Unwrap() error { return e.error }
+
+// NotFound wraps err so it is reported to the daemon as a missing object.
+// The message of err is preserved.
+func NotFound(err error) error {
+	if err == nil || IsNotFound(err) {
+		return err
+	}
+	return errNotFound{err}
+}
+
+// Conflict wraps err so it is reported to the daemon as a conflict.
+func Conflict(err error) error {
+	if err == nil || IsConflict(err) {
+		return err
+	}
+	return errConflict{err}
+}
+
+// InvalidArgument wraps err so it is reported to the daemon as a bad request.
+func InvalidArgument(err error) error {
+	if err == nil || IsInvalidArgument(err) {
+		return err
+	}
+	return errInvalidArgument{err}
+}
+
+// Unavailable wraps err so it is reported to the daemon as a temporary failure.
+func Unavailable(err error) error {
+	if err == nil || IsUnavailable(err) {
+		return err
+	}
+	return errUnavailable{err}
+}
+
+// IsNotFound returns whether err, or any error it wraps, is an ErrNotFound.
+func IsNotFound(err error) bool {
+	var e ErrNotFound
+	return errors.As(err, &e)
+}
+
+// IsConflict returns whether err, or any error it wraps, is an ErrConflict.
+func IsConflict(err error) bool {
+	var e ErrConflict
+	return errors.As(err, &e)
+}
+
+// IsInvalidArgument returns whether err, or any error it wraps, is an ErrInvalidArgument.
+func IsInvalidArgument(err error) bool {
+	var e ErrInvalidArgument
+	return errors.As(err, &e)
+}
+
+// IsUnavailable returns whether err, or any error it wraps, is an ErrUnavailable.
+func IsUnavailable(err error) bool {
+	var e ErrUnavailable
+	return errors.As(err, &e)
+}
+
+// StatusCode returns the http status code the daemon expects for err.
+// Errors that don't implement any of the typed error interfaces are
+// reported as internal server errors.
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case IsNotFound(err):
+		return http.StatusNotFound
+	case IsConflict(err):
+		return http.StatusConflict
+	case IsInvalidArgument(err):
+		return http.StatusBadRequest
+	case IsUnavailable(err):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -2,10 +2,13 @@ package sdk
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"os"
+	"path"
 )
 
 const activatePath = "/Plugin.Activate"
@@ -13,19 +16,28 @@ const activatePath = "/Plugin.Activate"
 // Handler is the base to create plugin handlers.
 // It initializes connections and sockets to listen to.
 type Handler struct {
-	mux *http.ServeMux
+	mux        *http.ServeMux
+	decodeMode *DecodeMode
+	implements []string
 }
 
 // NewHandler creates a new Handler with an http mux.
+// Requests to paths without a registered handler are answered with a not
+// found error in the plugin protocol format.
 func NewHandler(manifest string) Handler {
-	mux := http.NewServeMux()
+	h := Handler{mux: http.NewServeMux(), decodeMode: new(DecodeMode)}
+	var m struct{ Implements []string }
+	if err := json.Unmarshal([]byte(manifest), &m); err == nil {
+		h.implements = m.Implements
+	}
 
-	mux.HandleFunc(activatePath, func(w http.ResponseWriter, r *http.Request) {
+	h.mux.HandleFunc("/", notFound)
+	h.HandleFunc(activatePath, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", DefaultContentTypeV1_1)
 		fmt.Fprintln(w, manifest)
 	})
 
-	return Handler{mux: mux}
+	return h
 }
 
 // Serve sets up the handler to serve requests on the passed in listener
@@ -66,6 +78,12 @@ func (h Handler) ServeUnix(addr string, gid int) error {
 	return h.Serve(l)
 }
 
+// ServeManaged makes the handler to listen for requests on the socket of the
+// managed plugin m, the one declared by its config.
+func (h Handler) ServeManaged(m *ManagedPlugin) error {
+	return h.ServeUnix(m.SocketPath(), 0)
+}
+
 // ServeWindows makes the handler to listen for request in a Windows named pipe.
 // It also creates the spec file in the right directory for docker to read.
 // Due to constrains for running Docker in Docker on Windows, data-root directory
@@ -83,6 +101,50 @@ func (h Handler) ServeWindows(addr, pluginName, daemonDir string, pipeConfig *Wi
 }
 
 // HandleFunc registers a function to handle a request path with.
+// The docker daemon calls every plugin method with POST, requests using any
+// other method are rejected before reaching fn.
 func (h Handler) HandleFunc(path string, fn func(w http.ResponseWriter, r *http.Request)) {
-	h.mux.HandleFunc(path, fn)
+	h.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			encodeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed for %s", r.Method, r.URL.Path))
+			return
+		}
+		fn(w, r.WithContext(withDecodeMode(r.Context(), *h.decodeMode)))
+	})
+}
+
+// SetDecodeMode sets how request bodies are decoded by DecodeRequest.
+// It must be called before the handler starts serving requests.
+func (h Handler) SetDecodeMode(mode DecodeMode) {
+	*h.decodeMode = mode
+}
+
+// Implements returns the protocols the handler implements, as listed in its
+// manifest.
+func (h Handler) Implements() []string {
+	return append([]string(nil), h.implements...)
+}
+
+// PluginConfig returns the config of a managed plugin serving the handler
+// with ServeUnix(addr), with the interface types of the protocols it
+// implements. The other fields are left for the plugin to fill.
+func (h Handler) PluginConfig(addr string) PluginConfig {
+	socket := addr + ".sock"
+	if path.IsAbs(addr) {
+		socket = path.Base(addr)
+	}
+	c := PluginConfig{Interface: PluginInterface{Socket: socket}}
+	for _, implements := range h.implements {
+		c.Interface.Types = append(c.Interface.Types, InterfaceType(implements))
+	}
+	return c
+}
+
+// notFound answers requests for methods the plugin doesn't implement. They
+// are logged, since they usually come from a daemon speaking a newer version
+// of the protocol than this library.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	log.Printf("plugin: unsupported call %s %s", r.Method, r.URL.Path)
+	encodeError(w, http.StatusNotFound, fmt.Sprintf("%s is not implemented by this plugin", r.URL.Path))
 }
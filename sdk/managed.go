@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+)
+
+// ManagedConfigPath is the path of the copy of config.json in the rootfs of
+// the plugins bundled by the bundle package, to be read with
+// ReadManagedPlugin. The daemon doesn't give managed plugins their config.
+const ManagedConfigPath = "/etc/docker-plugin/" + PluginConfigFile
+
+// ManagedPlugin is the environment of a managed plugin, described by its
+// config.
+type ManagedPlugin struct {
+	*PluginConfig
+}
+
+// NewManagedPlugin returns the environment of the managed plugin created
+// with config.
+func NewManagedPlugin(config *PluginConfig) *ManagedPlugin {
+	return &ManagedPlugin{config}
+}
+
+// ReadManagedPlugin returns the environment of the managed plugin created
+// with the config at path, such as ManagedConfigPath for plugins bundled by
+// the bundle package.
+func ReadManagedPlugin(path string) (*ManagedPlugin, error) {
+	config, err := ReadPluginConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ManagedPlugin{config}, nil
+}
+
+// SocketPath returns the path of the socket the daemon connects to.
+func (m *ManagedPlugin) SocketPath() string {
+	return path.Join(pluginSockDir, m.Interface.Socket)
+}
+
+// Args returns the arguments of the plugin, following its entrypoint.
+func (m *ManagedPlugin) Args() []string {
+	if len(os.Args) <= len(m.Entrypoint) {
+		return nil
+	}
+	return os.Args[len(m.Entrypoint):]
+}
+
+// Env returns the value of the environment variable name, as set with
+// docker plugin set or declared in the config. Variables not declared in
+// the config are an error.
+func (m *ManagedPlugin) Env(name string) (string, error) {
+	for _, e := range m.PluginConfig.Env {
+		if e.Name != name {
+			continue
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v, nil
+		}
+		if e.Value != nil {
+			return *e.Value, nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("env %s is not declared in %s", name, PluginConfigFile)
+}
+
+// DecodeSettings sets the fields of the structure v points to from the
+// environment variables named by their env tag:
+//
+//	var settings struct {
+//		Debug   bool          `env:"DEBUG"`
+//		Timeout time.Duration `env:"TIMEOUT"`
+//	}
+//
+// Fields can be of the types set by SetValue, such as numbers, durations,
+// sizes or slices of strings separated by commas. Fields of empty
+// variables are left unchanged.
+func (m *ManagedPlugin) DecodeSettings(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("settings must be a pointer to a structure, not %T", v)
+	}
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name := rv.Type().Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		if !rv.Field(i).CanSet() {
+			return fmt.Errorf("env %s: unexported field %s", name, rv.Type().Field(i).Name)
+		}
+		value, err := m.Env(name)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		if err := SetValue(rv.Field(i), value, nil); err != nil {
+			return fmt.Errorf("env %s: %v", name, err)
+		}
+	}
+	return nil
+}
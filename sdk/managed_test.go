@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeSettings(t *testing.T) {
+	debug, timeout := "false", "5s"
+	m := &ManagedPlugin{&PluginConfig{Env: []PluginEnv{
+		{Name: "DEBUG", Value: &debug},
+		{Name: "TIMEOUT", Value: &timeout},
+		{Name: "HOSTS"},
+		{Name: "RETRIES"},
+		{Name: "CACHE"},
+	}}}
+	t.Setenv("DEBUG", "true")
+	t.Setenv("HOSTS", "a, b")
+	t.Setenv("CACHE", "64m")
+
+	var settings struct {
+		Debug   bool          `env:"DEBUG"`
+		Timeout time.Duration `env:"TIMEOUT"`
+		Hosts   []string      `env:"HOSTS"`
+		Retries int           `env:"RETRIES"`
+		Cache   Size          `env:"CACHE"`
+		Other   string
+	}
+	settings.Retries = 3
+	if err := m.DecodeSettings(&settings); err != nil {
+		t.Fatal(err)
+	}
+	if !settings.Debug || settings.Timeout != 5*time.Second || !reflect.DeepEqual(settings.Hosts, []string{"a", "b"}) || settings.Retries != 3 || settings.Cache != 64<<20 {
+		t.Fatalf("unexpected settings %+v", settings)
+	}
+
+	for _, tc := range []struct {
+		settings interface{}
+		err      string
+	}{
+		{settings, "settings must be a pointer to a structure, not struct"},
+		{&struct {
+			Missing string `env:"MISSING"`
+		}{}, "env MISSING is not declared in config.json"},
+		{&struct {
+			debug bool `env:"DEBUG"`
+		}{}, "env DEBUG: unexported field debug"},
+		{&struct {
+			Debug int `env:"DEBUG"`
+		}{}, "env DEBUG: "},
+	} {
+		err := m.DecodeSettings(tc.settings)
+		if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+			t.Errorf("%T: expected error %q, got %v", tc.settings, tc.err, err)
+		}
+	}
+}
+
+func TestReadManagedPlugin(t *testing.T) {
+	dir := t.TempDir()
+	config := validPluginConfig()
+	if err := config.WriteFile(dir); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManagedPlugin(filepath.Join(dir, PluginConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := m.SocketPath(); p != "/run/docker/plugins/test.sock" {
+		t.Fatalf("unexpected socket path %s", p)
+	}
+	if _, err := ReadManagedPlugin(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected an error reading a missing config")
+	}
+}
@@ -0,0 +1,286 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PluginConfigFile is the name of the config file of managed plugins,
+	// next to their rootfs directory.
+	PluginConfigFile = "config.json"
+	// pluginSockDir is the directory of plugin sockets, on the host and in
+	// the rootfs of managed plugins.
+	pluginSockDir = "/run/docker/plugins"
+)
+
+// PluginConfig is the config.json file of a managed plugin, read by docker
+// plugin create.
+type PluginConfig struct {
+	Description   string          `json:"description"`
+	Documentation string          `json:"documentation"`
+	Interface     PluginInterface `json:"interface"`
+	// Entrypoint is the command starting the plugin in its rootfs.
+	Entrypoint []string      `json:"entrypoint"`
+	WorkDir    string        `json:"workdir,omitempty"`
+	User       *PluginUser   `json:"user,omitempty"`
+	Network    PluginNetwork `json:"network"`
+	Linux      PluginLinux   `json:"linux"`
+	// PropagatedMount is the directory of the plugin whose mounts are
+	// propagated to the host, where volume drivers mount volumes.
+	PropagatedMount string        `json:"propagatedMount,omitempty"`
+	IpcHost         bool          `json:"ipchost,omitempty"`
+	PidHost         bool          `json:"pidhost,omitempty"`
+	Mounts          []PluginMount `json:"mounts,omitempty"`
+	Env             []PluginEnv   `json:"env,omitempty"`
+	Args            PluginArgs    `json:"args"`
+}
+
+// PluginInterface describes the protocols a managed plugin implements, and
+// the socket it serves them on.
+type PluginInterface struct {
+	// Types are the protocols implemented, such as docker.volumedriver/1.0.
+	Types []string `json:"types"`
+	// Socket is the name of the socket in /run/docker/plugins in the
+	// rootfs of the plugin.
+	Socket         string `json:"socket"`
+	ProtocolScheme string `json:"protocolscheme,omitempty"`
+}
+
+// PluginUser is the user running a managed plugin.
+type PluginUser struct {
+	UID uint32 `json:"UID,omitempty"`
+	GID uint32 `json:"GID,omitempty"`
+}
+
+// PluginNetwork is the network of a managed plugin.
+type PluginNetwork struct {
+	// Type is host, bridge or none.
+	Type string `json:"type"`
+}
+
+// PluginLinux holds the Linux settings of a managed plugin.
+type PluginLinux struct {
+	// Capabilities are the names of the capabilities granted, such as
+	// CAP_SYS_ADMIN.
+	Capabilities    []string       `json:"capabilities"`
+	AllowAllDevices bool           `json:"allowAllDevices"`
+	Devices         []PluginDevice `json:"devices"`
+}
+
+// PluginDevice is a device of the host exposed to a managed plugin. Path
+// is settable.
+type PluginDevice struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Settable    []string `json:"settable"`
+	Path        *string  `json:"path"`
+}
+
+// PluginMount is a mount of a managed plugin. Source is settable.
+type PluginMount struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Settable    []string `json:"settable"`
+	Source      *string  `json:"source"`
+	Destination string   `json:"destination"`
+	Type        string   `json:"type"`
+	Options     []string `json:"options"`
+}
+
+// PluginEnv is an environment variable of a managed plugin. Value is
+// settable.
+type PluginEnv struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Settable    []string `json:"settable"`
+	Value       *string  `json:"value"`
+}
+
+// PluginArgs are the arguments appended to the entrypoint of a managed
+// plugin. Value is settable.
+type PluginArgs struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Settable    []string `json:"settable"`
+	Value       []string `json:"value"`
+}
+
+// InterfaceType returns the interface type of managed plugins implementing
+// the protocol named implements in the plugin manifest.
+func InterfaceType(implements string) string {
+	return "docker." + strings.ToLower(implements) + "/1.0"
+}
+
+// ReadPluginConfig reads and validates the config file at path.
+func ReadPluginConfig(path string) (*PluginConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c PluginConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// WriteFile validates the config and writes it to the config file of the
+// plugin bundle in dir.
+func (c *PluginConfig) WriteFile(dir string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, PluginConfigFile), append(b, '\n'), 0644)
+}
+
+// CheckSocket checks that the socket of the config is the one ServeUnix(addr)
+// listens on.
+func (c *PluginConfig) CheckSocket(addr string) error {
+	socket := addr + ".sock"
+	if path.IsAbs(addr) {
+		if path.Dir(addr) != pluginSockDir {
+			return fmt.Errorf("socket %s is not in %s", addr, pluginSockDir)
+		}
+		socket = path.Base(addr)
+	}
+	if c.Interface.Socket != socket {
+		return fmt.Errorf("interface: socket %q doesn't match %q, the socket of %s", c.Interface.Socket, socket, addr)
+	}
+	return nil
+}
+
+// CheckMountpoint checks that mountpoint is under the propagated mount of
+// the config, the only mounts of the plugin visible to the daemon.
+func (c *PluginConfig) CheckMountpoint(mountpoint string) error {
+	if c.PropagatedMount == "" {
+		return fmt.Errorf("mountpoint %s: no propagated mount", mountpoint)
+	}
+	root, p := path.Clean(c.PropagatedMount), path.Clean(mountpoint)
+	if !path.IsAbs(mountpoint) || (p != root && !strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")) {
+		return fmt.Errorf("mountpoint %s is not under the propagated mount %s", mountpoint, c.PropagatedMount)
+	}
+	return nil
+}
+
+// Validate checks the config, and returns the errors of all its invalid
+// fields.
+func (c *PluginConfig) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if len(c.Interface.Types) == 0 {
+		fail("interface: no types")
+	}
+	for _, t := range c.Interface.Types {
+		name, version, ok := strings.Cut(t, "/")
+		if !ok || version == "" || !strings.HasPrefix(name, "docker.") || len(name) == len("docker.") {
+			fail("interface: invalid type %q", t)
+		}
+	}
+	if s := c.Interface.Socket; s == "" || strings.ContainsRune(s, '/') {
+		fail("interface: invalid socket %q", s)
+	}
+	if len(c.Entrypoint) == 0 {
+		fail("entrypoint: empty")
+	}
+	switch c.Network.Type {
+	case "", "host", "bridge", "none":
+	default:
+		fail("network: invalid type %q", c.Network.Type)
+	}
+	for _, capability := range c.Linux.Capabilities {
+		if !validCapability(capability) {
+			fail("linux: invalid capability %q", capability)
+		}
+	}
+	if c.PropagatedMount != "" && !path.IsAbs(c.PropagatedMount) {
+		fail("propagatedMount: %q is not absolute", c.PropagatedMount)
+	}
+	if c.WorkDir != "" && !path.IsAbs(c.WorkDir) {
+		fail("workdir: %q is not absolute", c.WorkDir)
+	}
+
+	for _, d := range c.Linux.Devices {
+		if err := validSettable(d.Settable, "path"); err != nil {
+			fail("device %s: %v", d.Name, err)
+		}
+		if d.Path != nil && !path.IsAbs(*d.Path) {
+			fail("device %s: path %q is not absolute", d.Name, *d.Path)
+		}
+	}
+	for _, m := range c.Mounts {
+		if err := validSettable(m.Settable, "source"); err != nil {
+			fail("mount %s: %v", m.Name, err)
+		}
+		if !path.IsAbs(m.Destination) {
+			fail("mount %s: destination %q is not absolute", m.Name, m.Destination)
+		}
+		if m.Type == "bind" && m.Source == nil && !contains(m.Settable, "source") {
+			fail("mount %s: bind mount without source", m.Name)
+		}
+	}
+	names := make(map[string]bool)
+	for _, e := range c.Env {
+		if e.Name == "" || strings.ContainsRune(e.Name, '=') {
+			fail("env: invalid name %q", e.Name)
+		} else if names[e.Name] {
+			fail("env: duplicate %s", e.Name)
+		}
+		names[e.Name] = true
+		if err := validSettable(e.Settable, "value"); err != nil {
+			fail("env %s: %v", e.Name, err)
+		}
+	}
+	if err := validSettable(c.Args.Settable, "value"); err != nil {
+		fail("args: %v", err)
+	}
+	return errors.Join(errs...)
+}
+
+// validCapability reports whether name is the name of a Linux capability.
+func validCapability(name string) bool {
+	suffix, ok := strings.CutPrefix(name, "CAP_")
+	if !ok || suffix == "" {
+		return false
+	}
+	for _, c := range suffix {
+		if c != '_' && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validSettable checks that settable only names the field allowed.
+func validSettable(settable []string, allowed string) error {
+	for _, s := range settable {
+		if s != allowed {
+			return fmt.Errorf("%s is not settable", s)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
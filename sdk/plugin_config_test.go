@@ -0,0 +1,167 @@
+package sdk
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validPluginConfig() PluginConfig {
+	source, value := "/var/lib/data", "info"
+	return PluginConfig{
+		Interface:  PluginInterface{Types: []string{"docker.volumedriver/1.0"}, Socket: "test.sock"},
+		Entrypoint: []string{"/bin/plugin"},
+		Network:    PluginNetwork{Type: "host"},
+		Linux: PluginLinux{
+			Capabilities: []string{"CAP_SYS_ADMIN"},
+			Devices:      []PluginDevice{{Name: "fuse", Settable: []string{"path"}}},
+		},
+		PropagatedMount: "/data",
+		Mounts: []PluginMount{
+			{Name: "state", Settable: []string{"source"}, Destination: "/state", Type: "bind"},
+			{Name: "data", Source: &source, Destination: "/mnt", Type: "bind"},
+		},
+		Env:  []PluginEnv{{Name: "LOG_LEVEL", Settable: []string{"value"}, Value: &value}},
+		Args: PluginArgs{Settable: []string{"value"}},
+	}
+}
+
+func TestPluginConfigValidate(t *testing.T) {
+	relative := "dev/fuse"
+	for _, tc := range []struct {
+		name   string
+		modify func(c *PluginConfig)
+		err    string
+	}{
+		{"valid", func(c *PluginConfig) {}, ""},
+		{"no network type", func(c *PluginConfig) { c.Network.Type = "" }, ""},
+		{"no types", func(c *PluginConfig) { c.Interface.Types = nil }, "interface: no types"},
+		{"type without version", func(c *PluginConfig) { c.Interface.Types = []string{"docker.volumedriver"} }, `interface: invalid type "docker.volumedriver"`},
+		{"type without docker prefix", func(c *PluginConfig) { c.Interface.Types = []string{"volumedriver/1.0"} }, `interface: invalid type "volumedriver/1.0"`},
+		{"type without name", func(c *PluginConfig) { c.Interface.Types = []string{"docker./1.0"} }, `interface: invalid type "docker./1.0"`},
+		{"no socket", func(c *PluginConfig) { c.Interface.Socket = "" }, `interface: invalid socket ""`},
+		{"socket path", func(c *PluginConfig) { c.Interface.Socket = "/run/test.sock" }, `interface: invalid socket "/run/test.sock"`},
+		{"no entrypoint", func(c *PluginConfig) { c.Entrypoint = nil }, "entrypoint: empty"},
+		{"network type", func(c *PluginConfig) { c.Network.Type = "overlay" }, `network: invalid type "overlay"`},
+		{"capability", func(c *PluginConfig) { c.Linux.Capabilities = []string{"SYS_ADMIN"} }, `linux: invalid capability "SYS_ADMIN"`},
+		{"lower case capability", func(c *PluginConfig) { c.Linux.Capabilities = []string{"CAP_sys_admin"} }, `linux: invalid capability "CAP_sys_admin"`},
+		{"propagated mount", func(c *PluginConfig) { c.PropagatedMount = "data" }, `propagatedMount: "data" is not absolute`},
+		{"workdir", func(c *PluginConfig) { c.WorkDir = "work" }, `workdir: "work" is not absolute`},
+		{"device settable", func(c *PluginConfig) { c.Linux.Devices[0].Settable = []string{"name"} }, "device fuse: name is not settable"},
+		{"device path", func(c *PluginConfig) { c.Linux.Devices[0].Path = &relative }, `device fuse: path "dev/fuse" is not absolute`},
+		{"mount settable", func(c *PluginConfig) { c.Mounts[1].Settable = []string{"destination"} }, "mount data: destination is not settable"},
+		{"mount destination", func(c *PluginConfig) { c.Mounts[1].Destination = "mnt" }, `mount data: destination "mnt" is not absolute`},
+		{"bind mount source", func(c *PluginConfig) { c.Mounts[1].Source = nil }, "mount data: bind mount without source"},
+		{"env name", func(c *PluginConfig) { c.Env[0].Name = "A=B" }, `env: invalid name "A=B"`},
+		{"env duplicate", func(c *PluginConfig) { c.Env = append(c.Env, c.Env[0]) }, "env: duplicate LOG_LEVEL"},
+		{"env settable", func(c *PluginConfig) { c.Env[0].Settable = []string{"name"} }, "env LOG_LEVEL: name is not settable"},
+		{"args settable", func(c *PluginConfig) { c.Args.Settable = []string{"name"} }, "args: name is not settable"},
+		{"all errors", func(c *PluginConfig) { c.Entrypoint, c.WorkDir = nil, "work" }, "entrypoint: empty\nworkdir: \"work\" is not absolute"},
+	} {
+		c := validPluginConfig()
+		tc.modify(&c)
+		err := c.Validate()
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: expected error %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestPluginConfigUser(t *testing.T) {
+	c := validPluginConfig()
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `"user"`) {
+		t.Errorf("expected no user without one, got %s", b)
+	}
+	c.User = &PluginUser{UID: 1000, GID: 1000}
+	if b, err = json.Marshal(c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"user":{"UID":1000,"GID":1000}`) {
+		t.Errorf("expected the user, got %s", b)
+	}
+}
+
+func TestPluginConfigCheckSocket(t *testing.T) {
+	c := validPluginConfig()
+	for _, tc := range []struct {
+		addr string
+		err  string
+	}{
+		{"test", ""},
+		{"/run/docker/plugins/test.sock", ""},
+		{"other", `interface: socket "test.sock" doesn't match "other.sock", the socket of other`},
+		{"/run/docker/plugins/other.sock", `interface: socket "test.sock" doesn't match "other.sock", the socket of /run/docker/plugins/other.sock`},
+		{"/run/test.sock", "socket /run/test.sock is not in /run/docker/plugins"},
+	} {
+		err := c.CheckSocket(tc.addr)
+		if (tc.err == "" && err != nil) || (tc.err != "" && (err == nil || err.Error() != tc.err)) {
+			t.Errorf("%s: expected error %q, got %v", tc.addr, tc.err, err)
+		}
+	}
+}
+
+func TestPluginConfigCheckMountpoint(t *testing.T) {
+	c := validPluginConfig()
+	for _, tc := range []struct {
+		mountpoint string
+		ok         bool
+	}{
+		{"/data", true},
+		{"/data/", true},
+		{"/data/volumes/foo", true},
+		{"/data/../data/foo", true},
+		{"/database", false},
+		{"/data/../etc", false},
+		{"data/foo", false},
+		{"/", false},
+	} {
+		if err := c.CheckMountpoint(tc.mountpoint); (err == nil) != tc.ok {
+			t.Errorf("%s: expected ok %v, got %v", tc.mountpoint, tc.ok, err)
+		}
+	}
+
+	c.PropagatedMount = "/"
+	if err := c.CheckMountpoint("/data/foo"); err != nil {
+		t.Errorf("expected mountpoints under / to be propagated, got %v", err)
+	}
+	c.PropagatedMount = ""
+	if err := c.CheckMountpoint("/data/foo"); err == nil || !strings.Contains(err.Error(), "no propagated mount") {
+		t.Errorf("expected no propagated mount, got %v", err)
+	}
+}
+
+func TestHandlerPluginConfig(t *testing.T) {
+	for _, tc := range []struct {
+		manifest string
+		addr     string
+		expected PluginInterface
+	}{
+		{`{"Implements": ["VolumeDriver"]}`, "test", PluginInterface{Types: []string{"docker.volumedriver/1.0"}, Socket: "test.sock"}},
+		{`{"Implements": ["NetworkDriver", "IpamDriver"]}`, "net", PluginInterface{Types: []string{"docker.networkdriver/1.0", "docker.ipamdriver/1.0"}, Socket: "net.sock"}},
+		{`{"Implements": ["authz"]}`, "/run/docker/plugins/authz.sock", PluginInterface{Types: []string{"docker.authz/1.0"}, Socket: "authz.sock"}},
+		{`{}`, "none", PluginInterface{Socket: "none.sock"}},
+	} {
+		c := NewHandler(tc.manifest).PluginConfig(tc.addr)
+		if !reflect.DeepEqual(c.Interface, tc.expected) {
+			t.Errorf("%s: expected interface %+v, got %+v", tc.manifest, tc.expected, c.Interface)
+		}
+		if err := c.CheckSocket(tc.addr); err != nil {
+			t.Errorf("%s: %v", tc.manifest, err)
+		}
+		// the config is left for the plugin to fill
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "entrypoint: empty") {
+			t.Errorf("%s: expected an incomplete config, got %v", tc.manifest, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package store persists the state of plugins, such as their volumes,
+// endpoints or allocations, across restarts.
+//
+// A Store is a typed key/value store kept in memory and saved to a JSON
+// file on every change. The file is replaced atomically, so it holds either
+// the state before or after a change when the plugin crashes. It records
+// the schema version of the values, and migrations bring files written by
+// previous versions of the plugin to the current one.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Migration migrates the values of a store from a schema version to the
+// next one. It can add, remove or rename values, and change their format.
+type Migration func(values map[string]json.RawMessage) (map[string]json.RawMessage, error)
+
+// Options configure a Store.
+type Options struct {
+	// Version is the schema version of the values, 0 when unset.
+	Version int
+	// Migrations are the migrations by schema version they migrate from.
+	// Files of a previous version need the migrations to every version up
+	// to Version.
+	Migrations map[int]Migration
+}
+
+// file is the format of the file of a store. Files holding a JSON object
+// of the values only are the version 0 of the store, such as the files
+// plugins wrote before using a Store.
+type file struct {
+	Version int
+	Values  map[string]json.RawMessage
+}
+
+// Store is a typed key/value store persisted to a file. It is safe for
+// concurrent use, but not to share the file between processes. Values are
+// copied by Put and Get as by assignment, values holding maps, slices or
+// pointers must not be modified once stored.
+type Store[V any] struct {
+	path    string
+	version int
+
+	mu     sync.RWMutex
+	values map[string]V
+}
+
+// Open opens the store persisted to the file at path, migrating its values
+// to the schema version of opts. The file is created by the first change
+// to a new store.
+func Open[V any](path string, opts Options) (*Store[V], error) {
+	s := &Store[V]{path: path, version: opts.Version, values: make(map[string]V)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := decodeFile(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if f.Version > opts.Version {
+		return nil, fmt.Errorf("%s: version %d is newer than %d", path, f.Version, opts.Version)
+	}
+	migrated := f.Version < opts.Version
+	for ; f.Version < opts.Version; f.Version++ {
+		migrate, ok := opts.Migrations[f.Version]
+		if !ok {
+			return nil, fmt.Errorf("%s: no migration from version %d", path, f.Version)
+		}
+		if f.Values, err = migrate(f.Values); err != nil {
+			return nil, fmt.Errorf("%s: migrating from version %d: %v", path, f.Version, err)
+		}
+	}
+	for k, raw := range f.Values {
+		var v V
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s: value %s: %v", path, k, err)
+		}
+		s.values[k] = v
+	}
+	if migrated {
+		if err := s.save(s.values); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func decodeFile(b []byte) (*file, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	if len(values) == 2 && values["Version"] != nil && values["Values"] != nil {
+		var f file
+		if err := json.Unmarshal(b, &f); err == nil {
+			return &f, nil
+		}
+	}
+	return &file{Values: values}, nil
+}
+
+// Get returns the value of key, and whether it is set.
+func (s *Store[V]) Get(key string) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Keys returns the sorted keys of the values.
+func (s *Store[V]) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Put sets the value of key.
+func (s *Store[V]) Put(key string, v V) error {
+	return s.Update(func(tx *Tx[V]) error {
+		tx.Put(key, v)
+		return nil
+	})
+}
+
+// Delete deletes the value of key.
+func (s *Store[V]) Delete(key string) error {
+	return s.Update(func(tx *Tx[V]) error {
+		tx.Delete(key)
+		return nil
+	})
+}
+
+// Update applies the changes fn makes to tx at once, unless fn fails.
+// Changes are only visible once saved, and other updates wait for fn to
+// return.
+func (s *Store[V]) Update(fn func(tx *Tx[V]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := &Tx[V]{values: make(map[string]V, len(s.values))}
+	for k, v := range s.values {
+		tx.values[k] = v
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if !tx.changed {
+		return nil
+	}
+	if err := s.save(tx.values); err != nil {
+		return err
+	}
+	s.values = tx.values
+	return nil
+}
+
+func (s *Store[V]) save(values map[string]V) error {
+	f := file{Version: s.version, Values: make(map[string]json.RawMessage, len(values))}
+	for k, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("value %s: %v", k, err)
+		}
+		f.Values[k] = b
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return WriteFile(s.path, b, 0600)
+}
+
+// Tx holds the values of a store during an update.
+type Tx[V any] struct {
+	values  map[string]V
+	changed bool
+}
+
+// Get returns the value of key, and whether it is set.
+func (tx *Tx[V]) Get(key string) (V, bool) {
+	v, ok := tx.values[key]
+	return v, ok
+}
+
+// Put sets the value of key.
+func (tx *Tx[V]) Put(key string, v V) {
+	tx.values[key] = v
+	tx.changed = true
+}
+
+// Delete deletes the value of key.
+func (tx *Tx[V]) Delete(key string) {
+	if _, ok := tx.values[key]; ok {
+		delete(tx.values, key)
+		tx.changed = true
+	}
+}
+
+// WriteFile writes data to the file at path atomically: data is written
+// and synced to a temporary file, renamed over the file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	// sync the directory for the rename to survive a crash, where supported
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
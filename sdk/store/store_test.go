@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type pool struct {
+	Subnet string
+	Used   int
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pools.json")
+	s, err := Open[pool](path, Options{Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("a", pool{"10.0.0.0/24", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("b", pool{"10.0.1.0/24", 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+
+	// failed updates change nothing
+	err = s.Update(func(tx *Tx[pool]) error {
+		tx.Delete("a")
+		return errors.New("failed")
+	})
+	if err == nil || err.Error() != "failed" {
+		t.Fatalf("expected the update error, got %v", err)
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Fatal("expected a to be kept")
+	}
+	err = s.Update(func(tx *Tx[pool]) error {
+		p, _ := tx.Get("b")
+		p.Used++
+		tx.Put("b", p)
+		tx.Delete("a")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open[pool](path, Options{Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := s.Keys(); !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Fatalf("expected keys [b], got %v", keys)
+	}
+	if p, _ := s.Get("b"); p != (pool{"10.0.1.0/24", 3}) {
+		t.Fatalf("unexpected value %+v", p)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the store file, got %v, %v", entries, err)
+	}
+
+	if _, err := Open[pool](path, Options{}); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("expected an error opening a newer version, got %v", err)
+	}
+	if _, err := Open[pool](path, Options{Version: 2}); err == nil || !strings.Contains(err.Error(), "no migration") {
+		t.Fatalf("expected an error without migration, got %v", err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	// files written before the store are version 0
+	path := filepath.Join(t.TempDir(), "pools.json")
+	if err := os.WriteFile(path, []byte(`{"a":"10.0.0.0/24"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Version: 2, Migrations: map[int]Migration{
+		0: func(values map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+			for k, v := range values {
+				values[k] = json.RawMessage(`{"Subnet":` + string(v) + `}`)
+			}
+			return values, nil
+		},
+		1: func(values map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+			values["pool-a"] = values["a"]
+			delete(values, "a")
+			return values, nil
+		},
+	}}
+	s, err := Open[pool](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Get("pool-a"); p.Subnet != "10.0.0.0/24" {
+		t.Fatalf("unexpected migrated value %+v", p)
+	}
+
+	// the migrated values are saved
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil || f.Version != 2 || len(f.Values) != 1 {
+		t.Fatalf("unexpected file %s, %v", b, err)
+	}
+
+	opts.Migrations[2] = func(map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		return nil, errors.New("failed")
+	}
+	opts.Version = 3
+	if _, err := Open[pool](path, opts); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Fatalf("expected the migration error, got %v", err)
+	}
+}
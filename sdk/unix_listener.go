@@ -10,8 +10,6 @@ import (
 	"github.com/docker/go-connections/sockets"
 )
 
-const pluginSockDir = "/run/docker/plugins"
-
 func newUnixListener(pluginName string, gid int) (net.Listener, string, error) {
 	path, err := fullSocketAddress(pluginName)
 	if err != nil {
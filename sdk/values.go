@@ -0,0 +1,206 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size is a size in bytes, parsed with an optional k, m, g or t suffix, as
+// powers of 1024.
+type Size int64
+
+// ParseSize parses a positive size with an optional k, m, g or t suffix.
+func ParseSize(s string) (Size, error) {
+	unit := Size(1)
+	num := strings.TrimSuffix(strings.ToLower(s), "b")
+	switch {
+	case strings.HasSuffix(num, "k"):
+		unit = 1 << 10
+	case strings.HasSuffix(num, "m"):
+		unit = 1 << 20
+	case strings.HasSuffix(num, "g"):
+		unit = 1 << 30
+	case strings.HasSuffix(num, "t"):
+		unit = 1 << 40
+	}
+	if unit > 1 {
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size", s)
+	}
+	return Size(n) * unit, nil
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	sizeType     = reflect.TypeOf(Size(0))
+	modeType     = reflect.TypeOf(os.FileMode(0))
+)
+
+// CheckValueType checks that SetValue can set values of type t.
+func CheckValueType(t reflect.Type) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case scalar(t):
+		return nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		return nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String:
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", t)
+}
+
+// ValueTypeName returns the name of the values of type t, such as <size>,
+// for the documentation of settings and options.
+func ValueTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case durationType:
+		return "<duration>"
+	case sizeType:
+		return "<size>"
+	case modeType:
+		return "<mode>"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "<bool>"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "<int>"
+	case reflect.Float32, reflect.Float64:
+		return "<number>"
+	case reflect.Slice:
+		return "<value>,..."
+	case reflect.Map:
+		return "<key>=<value>,..."
+	}
+	return "<string>"
+}
+
+// SetValue parses s and sets the settable value v to it, for decoders of
+// settings and options. Values can be strings, booleans, decimal numbers,
+// durations, sizes, modes in octal, slices of strings separated by commas,
+// maps of strings set by comma separated key=value pairs, or pointers to
+// them. When allowed is set, the values, the items of slices and the keys
+// of maps must be one of them.
+func SetValue(v reflect.Value, s string, allowed []string) error {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Ptr:
+		p := reflect.New(t.Elem())
+		if err := SetValue(p.Elem(), s, allowed); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		items := strings.Split(s, ",")
+		sv := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			item = strings.TrimSpace(item)
+			if err := checkAllowed(item, allowed); err != nil {
+				return err
+			}
+			sv.Index(i).SetString(item)
+		}
+		v.Set(sv)
+		return nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String:
+		m := reflect.MakeMap(t)
+		for _, pair := range strings.Split(s, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if key = strings.TrimSpace(key); !ok || key == "" {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			if err := checkAllowed(key, allowed); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), reflect.ValueOf(strings.TrimSpace(value)).Convert(t.Elem()))
+		}
+		v.Set(m)
+		return nil
+	case !scalar(t):
+		return fmt.Errorf("unsupported type %s", t)
+	}
+
+	if err := checkAllowed(s, allowed); err != nil {
+		return err
+	}
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.New("not a duration")
+		}
+		v.SetInt(int64(d))
+	case t == sizeType:
+		n, err := ParseSize(s)
+		if err != nil {
+			return errors.New("not a positive size")
+		}
+		v.SetInt(int64(n))
+	case t == modeType:
+		n, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || os.FileMode(n)&^os.ModePerm != 0 {
+			return errors.New("not an octal permission mode")
+		}
+		v.SetUint(n)
+	case t.Kind() == reflect.String:
+		v.SetString(s)
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		v.SetBool(b)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		v.SetInt(n)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return errors.New("not a positive integer")
+		}
+		v.SetUint(n)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return errors.New("not a number")
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+func scalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func checkAllowed(value string, allowed []string) error {
+	if len(allowed) == 0 || contains(allowed, value) {
+		return nil
+	}
+	return fmt.Errorf("%q is not one of %s", value, strings.Join(allowed, ", "))
+}
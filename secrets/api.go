@@ -1,11 +1,13 @@
 package secrets
 
 import (
-	"net/http"
+	"encoding/json"
 
 	"github.com/docker/go-plugins-helpers/sdk"
 )
 
+//go:generate go run ../cmd/protocolgen
+
 const (
 	manifest = `{"Implements": ["secretprovider"]}`
 	getPath  = "/SecretProvider.GetSecret"
@@ -23,6 +25,9 @@ type Request struct {
 	TaskName            string            `json:",omitempty"` // TaskName is the name of the task that the secret is assigned to
 	TaskImage           string            `json:",omitempty"` // TaskName is the image of the task that the secret is assigned to
 	ServiceEndpointSpec *EndpointSpec     `json:",omitempty"` // ServiceEndpointSpec holds the specification for endpoints
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // Response contains the plugin secret value
@@ -54,12 +59,6 @@ type PortConfig struct {
 	PublishMode int32 `json:",omitempty"`
 }
 
-// Driver represent the interface a driver must fulfill.
-type Driver interface {
-	// Get gets a secret from a remote secret store
-	Get(Request) Response
-}
-
 // Handler forwards requests and responses between the docker daemon and the plugin.
 type Handler struct {
 	driver Driver
@@ -72,14 +71,3 @@ func NewHandler(driver Driver) *Handler {
 	h.initMux()
 	return h
 }
-
-func (h *Handler) initMux() {
-	h.HandleFunc(getPath, func(w http.ResponseWriter, r *http.Request) {
-		var req Request
-		if err := sdk.DecodeRequest(w, r, &req); err != nil {
-			return
-		}
-		res := h.driver.Get(req)
-		sdk.EncodeResponse(w, res, res.Err != "")
-	})
-}
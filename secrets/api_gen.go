@@ -0,0 +1,26 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package secrets
+
+import (
+	"net/http"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Driver represent the interface a driver must fulfill.
+type Driver interface {
+	// Get gets a secret from a remote secret store
+	Get(Request) Response
+}
+
+func (h *Handler) initMux() {
+	h.HandleFunc(getPath, func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := sdk.DecodeRequest(w, r, &req); err != nil {
+			return
+		}
+		res := h.driver.Get(req)
+		sdk.EncodeResponse(w, res, res.Err != "")
+	})
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
 )
 
 func TestHandler(t *testing.T) {
@@ -55,6 +57,27 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestHandlerUnknownFields(t *testing.T) {
+	p := &testPlugin{}
+	h := NewHandler(p)
+	h.SetDecodeMode(sdk.DecodeCollect)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: l.Dial,
+	}}
+	resp, err := client.Post("http://localhost"+getPath, "application/json", strings.NewReader(`{"SecretName":"my-secret","SecretDriver":"vault"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(p.last.Unknown) != 1 || string(p.last.Unknown["SecretDriver"]) != `"vault"` {
+		t.Fatalf("expected SecretDriver unknown field, got %v", p.last.Unknown)
+	}
+}
+
 func pluginRequest(client *http.Client, method string, req Request) (*Response, error) {
 	b, err := json.Marshal(req)
 	if err != nil {
@@ -74,13 +97,15 @@ func pluginRequest(client *http.Client, method string, req Request) (*Response,
 }
 
 type testPlugin struct {
-	get int
+	get  int
+	last Request
 }
 
 var secret = []byte("secret")
 
 func (p *testPlugin) Get(req Request) Response {
 	p.get++
+	p.last = req
 	if req.SecretName == "" {
 		return Response{Err: "missing secret name"}
 	}
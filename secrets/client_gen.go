@@ -0,0 +1,28 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package secrets
+
+import "github.com/docker/go-plugins-helpers/sdk"
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Driver, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Driver = (*Client)(nil)
+
+// Get calls the Get method of the plugin.
+// Errors are reported in the Err field of the response.
+func (c *Client) Get(req Request) Response {
+	var res Response
+	if err := c.client.Call(getPath, req, &res); err != nil {
+		return Response{Err: err.Error()}
+	}
+	return res
+}
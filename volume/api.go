@@ -1,11 +1,13 @@
 package volume
 
 import (
-	"net/http"
+	"encoding/json"
 
 	"github.com/docker/go-plugins-helpers/sdk"
 )
 
+//go:generate go run ../cmd/protocolgen
+
 const (
 	// DefaultDockerRootDirectory is the default directory where volumes will be created.
 	DefaultDockerRootDirectory = "/var/lib/docker-volumes"
@@ -25,17 +27,26 @@ const (
 type CreateRequest struct {
 	Name    string
 	Options map[string]string `json:"Opts,omitempty"`
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // RemoveRequest structure for a volume remove request
 type RemoveRequest struct {
 	Name string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // MountRequest structure for a volume mount request
 type MountRequest struct {
 	Name string
 	ID   string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // MountResponse structure for a volume mount response
@@ -47,11 +58,17 @@ type MountResponse struct {
 type UnmountRequest struct {
 	Name string
 	ID   string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // PathRequest structure for a volume path request
 type PathRequest struct {
 	Name string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // PathResponse structure for a volume path response
@@ -62,6 +79,9 @@ type PathResponse struct {
 // GetRequest structure for a volume get request
 type GetRequest struct {
 	Name string
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
 }
 
 // GetResponse structure for a volume get response
@@ -87,6 +107,21 @@ type Volume struct {
 	Status     map[string]interface{} `json:",omitempty"`
 }
 
+// withStatus returns a copy of v with status added to its status, for
+// wrappers reporting their own state. The volumes returned by a driver may
+// be its own, so v and its status are left unchanged.
+func withStatus(v *Volume, status map[string]interface{}) *Volume {
+	vol := *v
+	vol.Status = make(map[string]interface{}, len(v.Status)+len(status))
+	for k, s := range v.Status {
+		vol.Status[k] = s
+	}
+	for k, s := range status {
+		vol.Status[k] = s
+	}
+	return &vol
+}
+
 // Capability represents the list of capabilities a volume driver can return
 type Capability struct {
 	Scope string
@@ -102,18 +137,6 @@ func NewErrorResponse(msg string) *ErrorResponse {
 	return &ErrorResponse{Err: msg}
 }
 
-// Driver represent the interface a driver must fulfill.
-type Driver interface {
-	Create(*CreateRequest) error
-	List() (*ListResponse, error)
-	Get(*GetRequest) (*GetResponse, error)
-	Remove(*RemoveRequest) error
-	Path(*PathRequest) (*PathResponse, error)
-	Mount(*MountRequest) (*MountResponse, error)
-	Unmount(*UnmountRequest) error
-	Capabilities() *CapabilitiesResponse
-}
-
 // Handler forwards requests and responses between the docker daemon and the plugin.
 type Handler struct {
 	driver Driver
@@ -121,101 +144,26 @@ type Handler struct {
 }
 
 // NewHandler initializes the request handler with a driver implementation.
+// The export and import extension routes are served for drivers implementing
+// Exporter.
 func NewHandler(driver Driver) *Handler {
+	exporter, _ := driver.(Exporter)
+	return newHandler(driver, exporter)
+}
+
+// NewManagedHandler is like NewHandler for the managed plugin m, checking
+// the mountpoints of driver with CheckMountpoints. It is served with
+// ServeManaged.
+func NewManagedHandler(driver Driver, m *sdk.ManagedPlugin) *Handler {
+	exporter, _ := driver.(Exporter)
+	return newHandler(CheckMountpoints(driver, m.PluginConfig), exporter)
+}
+
+func newHandler(driver Driver, exporter Exporter) *Handler {
 	h := &Handler{driver, sdk.NewHandler(manifest)}
 	h.initMux()
+	if exporter != nil {
+		h.initExportMux(exporter)
+	}
 	return h
 }
-
-func (h *Handler) initMux() {
-	h.HandleFunc(createPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &CreateRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.Create(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(removePath, func(w http.ResponseWriter, r *http.Request) {
-		req := &RemoveRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.Remove(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(mountPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &MountRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.Mount(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(hostVirtualPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &PathRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.Path(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(getPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &GetRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		res, err := h.driver.Get(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-	h.HandleFunc(unmountPath, func(w http.ResponseWriter, r *http.Request) {
-		req := &UnmountRequest{}
-		err := sdk.DecodeRequest(w, r, req)
-		if err != nil {
-			return
-		}
-		err = h.driver.Unmount(req)
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, struct{}{}, false)
-	})
-	h.HandleFunc(listPath, func(w http.ResponseWriter, r *http.Request) {
-		res, err := h.driver.List()
-		if err != nil {
-			sdk.EncodeResponse(w, NewErrorResponse(err.Error()), true)
-			return
-		}
-		sdk.EncodeResponse(w, res, false)
-	})
-
-	h.HandleFunc(capabilitiesPath, func(w http.ResponseWriter, r *http.Request) {
-		sdk.EncodeResponse(w, h.driver.Capabilities(), false)
-	})
-}
@@ -1,14 +1,23 @@
 package volume
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
 )
 
 func TestHandler(t *testing.T) {
@@ -111,6 +120,106 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestHandlerErrors(t *testing.T) {
+	p := &testPlugin{volumes: []string{"busy"}}
+	h := NewHandler(p)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: l.Dial,
+	}}
+
+	for _, tc := range []struct {
+		path   string
+		body   string
+		status int
+		err    string
+	}{
+		{getPath, `{"Name":"foo"}`, http.StatusNotFound, "no such volume"},
+		{removePath, `{"Name":"busy"}`, http.StatusConflict, "volume is in use"},
+		{mountPath, `{"Name":"foo"}`, http.StatusInternalServerError, "no such volume"},
+		{createPath, `{"Name":`, http.StatusBadRequest, "unexpected EOF"},
+	} {
+		resp, err := client.Post("http://localhost"+tc.path, sdk.DefaultContentTypeV1_1, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var eResp ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&eResp)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s: expected status %d, got %d", tc.path, tc.status, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != sdk.DefaultContentTypeV1_1 {
+			t.Fatalf("%s: expected content type %s, got %s", tc.path, sdk.DefaultContentTypeV1_1, ct)
+		}
+		if eResp.Err != tc.err {
+			t.Fatalf("%s: expected error %q, got %q", tc.path, tc.err, eResp.Err)
+		}
+	}
+	if p.create != 0 {
+		t.Fatalf("expected create 0, got %d", p.create)
+	}
+}
+
+func TestHandlerDecodeModes(t *testing.T) {
+	p := &testPlugin{}
+	h := NewHandler(p)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: l.Dial,
+	}}
+	body := `{"Name":"foo","Opts":{"size":"1G"},"Labels":{"a":"b"}}`
+
+	// Lenient
+	if _, err := pluginRequest(client, createPath, json.RawMessage(body)); err != nil {
+		t.Fatal(err)
+	}
+	if p.create != 1 {
+		t.Fatalf("expected create 1, got %d", p.create)
+	}
+	if p.lastCreate.Unknown != nil {
+		t.Fatalf("expected no unknown fields, got %v", p.lastCreate.Unknown)
+	}
+
+	// Collect
+	h.SetDecodeMode(sdk.DecodeCollect)
+	if _, err := pluginRequest(client, createPath, json.RawMessage(body)); err != nil {
+		t.Fatal(err)
+	}
+	if p.create != 2 {
+		t.Fatalf("expected create 2, got %d", p.create)
+	}
+	if p.lastCreate.Options["size"] != "1G" {
+		t.Fatalf("expected size option 1G, got %v", p.lastCreate.Options)
+	}
+	if len(p.lastCreate.Unknown) != 1 || string(p.lastCreate.Unknown["Labels"]) != `{"a":"b"}` {
+		t.Fatalf("expected Labels unknown field, got %v", p.lastCreate.Unknown)
+	}
+
+	// Strict
+	h.SetDecodeMode(sdk.DecodeStrict)
+	resp, err := client.Post("http://localhost"+createPath, sdk.DefaultContentTypeV1_1, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if p.create != 2 {
+		t.Fatalf("expected create 2, got %d", p.create)
+	}
+}
+
 func pluginRequest(client *http.Client, method string, req interface{}) (io.Reader, error) {
 	b, err := json.Marshal(req)
 	if err != nil {
@@ -129,6 +238,7 @@ func pluginRequest(client *http.Client, method string, req interface{}) (io.Read
 
 type testPlugin struct {
 	volumes      []string
+	lastCreate   *CreateRequest
 	create       int
 	get          int
 	list         int
@@ -141,6 +251,7 @@ type testPlugin struct {
 
 func (p *testPlugin) Create(req *CreateRequest) error {
 	p.create++
+	p.lastCreate = req
 	p.volumes = append(p.volumes, req.Name)
 	return nil
 }
@@ -152,7 +263,7 @@ func (p *testPlugin) Get(req *GetRequest) (*GetResponse, error) {
 			return &GetResponse{Volume: &Volume{Name: v}}, nil
 		}
 	}
-	return &GetResponse{}, fmt.Errorf("no such volume")
+	return &GetResponse{}, sdk.NotFound(errors.New("no such volume"))
 }
 
 func (p *testPlugin) List() (*ListResponse, error) {
@@ -166,6 +277,9 @@ func (p *testPlugin) List() (*ListResponse, error) {
 
 func (p *testPlugin) Remove(req *RemoveRequest) error {
 	p.remove++
+	if req.Name == "busy" {
+		return sdk.Conflict(fmt.Errorf("volume is in use"))
+	}
 	for i, v := range p.volumes {
 		if v == req.Name {
 			p.volumes = append(p.volumes[:i], p.volumes[i+1:]...)
@@ -209,3 +323,918 @@ func (p *testPlugin) Capabilities() *CapabilitiesResponse {
 	p.capabilities++
 	return &CapabilitiesResponse{Capabilities: Capability{Scope: "local"}}
 }
+
+// mountpointDriver mounts every volume at mountpoint.
+type mountpointDriver struct {
+	Driver
+	mountpoint string
+	unmount    int
+}
+
+func (d *mountpointDriver) Mount(req *MountRequest) (*MountResponse, error) {
+	return &MountResponse{Mountpoint: d.mountpoint}, nil
+}
+
+func (d *mountpointDriver) Unmount(req *UnmountRequest) error {
+	d.unmount++
+	return nil
+}
+
+func (d *mountpointDriver) Path(req *PathRequest) (*PathResponse, error) {
+	return &PathResponse{Mountpoint: d.mountpoint}, nil
+}
+
+func (d *mountpointDriver) Get(req *GetRequest) (*GetResponse, error) {
+	return &GetResponse{Volume: &Volume{Name: req.Name, Mountpoint: d.mountpoint}}, nil
+}
+
+func (d *mountpointDriver) List() (*ListResponse, error) {
+	return &ListResponse{Volumes: []*Volume{{Name: "foo", Mountpoint: d.mountpoint}}}, nil
+}
+
+func TestCheckMountpoints(t *testing.T) {
+	config := &sdk.PluginConfig{PropagatedMount: "/mnt/volumes"}
+	for _, tc := range []struct {
+		mountpoint string
+		valid      bool
+	}{
+		{"/mnt/volumes/foo", true},
+		{"/mnt/volumes", true},
+		{"/mnt/volumes/foo/../bar", true},
+		{"/mnt/volumes2/foo", false},
+		{"/mnt/volumes/../foo", false},
+		{"mnt/volumes/foo", false},
+		{"/var/lib/foo", false},
+	} {
+		p := &mountpointDriver{mountpoint: tc.mountpoint}
+		d := CheckMountpoints(p, config)
+		_, mountErr := d.Mount(&MountRequest{Name: "foo", ID: "c1"})
+		_, pathErr := d.Path(&PathRequest{Name: "foo"})
+		_, getErr := d.Get(&GetRequest{Name: "foo"})
+		_, listErr := d.List()
+		for _, err := range []error{mountErr, pathErr, getErr, listErr} {
+			if (err == nil) != tc.valid {
+				t.Fatalf("%s: expected valid %v, got %v", tc.mountpoint, tc.valid, err)
+			}
+		}
+		// rejected mounts are undone
+		if unmount := map[bool]int{true: 0, false: 1}[tc.valid]; p.unmount != unmount {
+			t.Fatalf("%s: expected %d unmounts, got %d", tc.mountpoint, unmount, p.unmount)
+		}
+	}
+
+	// volumes that aren't mounted have no mountpoint
+	d := CheckMountpoints(&mountpointDriver{}, config)
+	if _, err := d.Path(&PathRequest{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "foo"}); err == nil {
+		t.Fatal("expected an error mounting without mountpoint")
+	}
+
+	// nil responses are forwarded, or rejected for mounts
+	p := &nilDriver{}
+	d = CheckMountpoints(p, config)
+	if res, err := d.List(); res != nil || err != nil {
+		t.Fatalf("expected a nil list, got %v, %v", res, err)
+	}
+	if res, err := d.Get(&GetRequest{Name: "foo"}); res != nil || err != nil {
+		t.Fatalf("expected a nil volume, got %v, %v", res, err)
+	}
+	if res, err := d.Path(&PathRequest{Name: "foo"}); res != nil || err != nil {
+		t.Fatalf("expected a nil path, got %v, %v", res, err)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "foo", ID: "c1"}); err == nil || p.unmount != 1 {
+		t.Fatalf("expected a mount without response to be undone, got %v and %d unmounts", err, p.unmount)
+	}
+
+	// the handlers of managed plugins check the mountpoints
+	h := NewManagedHandler(&mountpointDriver{Driver: &testPlugin{}, mountpoint: "/var/lib/foo"}, sdk.NewManagedPlugin(config))
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+	if _, err := c.Mount(&MountRequest{Name: "foo", ID: "c1"}); err == nil {
+		t.Fatal("expected an error mounting outside of the propagated mount")
+	}
+}
+
+// nilDriver replies to every request without response.
+type nilDriver struct {
+	Driver
+	unmount int
+}
+
+func (d *nilDriver) List() (*ListResponse, error)                    { return nil, nil }
+func (d *nilDriver) Get(req *GetRequest) (*GetResponse, error)       { return nil, nil }
+func (d *nilDriver) Path(req *PathRequest) (*PathResponse, error)    { return nil, nil }
+func (d *nilDriver) Mount(req *MountRequest) (*MountResponse, error) { return nil, nil }
+func (d *nilDriver) Unmount(req *UnmountRequest) error               { d.unmount++; return nil }
+
+func TestRefCountDriver(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "mounts.json")
+	p := &testPlugin{volumes: []string{"foo", "bar"}}
+	d, err := NewRefCountDriver(p, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"c1", "c2", "c2"} {
+		if _, err := d.Mount(&MountRequest{Name: "foo", ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Mount(&MountRequest{Name: "bar", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.mount != 2 {
+		t.Fatalf("expected mount 2, got %d", p.mount)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "baz", ID: "c1"}); err == nil {
+		t.Fatal("expected an error mounting an unknown volume")
+	}
+	if err := d.Remove(&RemoveRequest{Name: "foo"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict removing a mounted volume, got %v", err)
+	}
+
+	// the mounts are counted across restarts
+	d, err = NewRefCountDriver(p, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Unmount(&UnmountRequest{Name: "foo", ID: "c3"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found unmounting an unknown mount, got %v", err)
+	}
+	if err := d.Unmount(&UnmountRequest{Name: "foo", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.unmount != 0 {
+		t.Fatalf("expected unmount 0, got %d", p.unmount)
+	}
+	if err := d.Unmount(&UnmountRequest{Name: "foo", ID: "c2"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.unmount != 1 {
+		t.Fatalf("expected unmount 1, got %d", p.unmount)
+	}
+	if err := d.Remove(&RemoveRequest{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.remove != 1 {
+		t.Fatalf("expected remove 1, got %d", p.remove)
+	}
+
+	// drivers mounting without response mount without mountpoint
+	n := &nilDriver{}
+	d, err = NewRefCountDriver(n, filepath.Join(t.TempDir(), "mounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := d.Mount(&MountRequest{Name: "foo", ID: "c1"}); err != nil || res.Mountpoint != "" {
+		t.Fatalf("unexpected mount %+v, %v", res, err)
+	}
+	if err := d.Unmount(&UnmountRequest{Name: "foo", ID: "c1"}); err != nil || n.unmount != 1 {
+		t.Fatalf("expected unmount 1, got %d, %v", n.unmount, err)
+	}
+
+	if err := os.WriteFile(state, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRefCountDriver(p, state); err == nil {
+		t.Fatal("expected an error loading a corrupted state")
+	}
+}
+
+type testOptions struct {
+	Size    Size              `opt:"size,required" help:"size of the volume"`
+	FS      string            `opt:"fs" default:"ext4" values:"ext4,xfs" help:"filesystem of the volume"`
+	Mode    os.FileMode       `opt:"mode" default:"0755"`
+	UID     *uint32           `opt:"uid"`
+	Sync    bool              `opt:"sync"`
+	Timeout time.Duration     `opt:"timeout" default:"10s"`
+	Devices []string          `opt:"devices"`
+	Labels  map[string]string `opt:"labels"`
+	Ignored string
+}
+
+func TestOptionsSchema(t *testing.T) {
+	schema, err := NewOptionsSchema(testOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts testOptions
+	err = schema.Decode(map[string]string{"size": "10g", "uid": "1000", "sync": "true", "devices": "sda, sdb", "labels": "env=prod,team=db"}, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Size != 10<<30 || opts.FS != "ext4" || opts.Mode != 0755 || opts.UID == nil || *opts.UID != 1000 || !opts.Sync || opts.Timeout != 10*time.Second {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if len(opts.Devices) != 2 || opts.Devices[1] != "sdb" || opts.Labels["env"] != "prod" || opts.Labels["team"] != "db" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+
+	opts = testOptions{}
+	err = schema.Decode(map[string]string{"fs": "btrfs", "mode": "1777", "sync": "maybe", "labels": "env", "uid": "", "tipe": "x", "zize": "1"}, &opts)
+	if !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+	for _, msg := range []string{
+		`missing required option size`,
+		`invalid option fs="btrfs": "btrfs" is not one of ext4, xfs`,
+		`invalid option mode="1777": not an octal permission mode`,
+		`invalid option sync="maybe": not a boolean`,
+		`invalid option labels="env": "env" is not a key=value pair`,
+		`invalid option uid="": empty value`,
+		`unknown option tipe, zize, supported options are devices, fs, labels, mode, size, sync, timeout, uid`,
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected %q in the error, got %v", msg, err)
+		}
+	}
+	if opts.UID != nil {
+		t.Fatalf("expected no uid, got %d", *opts.UID)
+	}
+	if err := schema.Decode(nil, opts); err == nil {
+		t.Fatal("expected an error decoding to a structure")
+	}
+
+	help := schema.Help()
+	for _, line := range []string{
+		"  fs=<string>               filesystem of the volume (default ext4, one of ext4, xfs)\n",
+		"  labels=<key>=<value>,...\n",
+		"  size=<size>               size of the volume (required)\n",
+		"  timeout=<duration>        (default 10s)\n",
+	} {
+		if !strings.Contains(help, line) {
+			t.Fatalf("expected %q in the help, got\n%s", line, help)
+		}
+	}
+
+	for _, v := range []interface{}{
+		struct {
+			A chan int `opt:"a"`
+		}{},
+		struct {
+			A string `opt:"a,optional"`
+		}{},
+		struct {
+			A int `opt:"a" default:"one"`
+		}{},
+		struct {
+			A string `opt:"a" default:"c" values:"a,b"`
+		}{},
+		struct {
+			A string `opt:"a"`
+			B string `opt:"a"`
+		}{},
+		"options",
+	} {
+		if _, err := NewOptionsSchema(v); err == nil {
+			t.Fatalf("expected an error declaring options with %T", v)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, size := range map[string]Size{"512": 512, "4k": 4 << 10, "10M": 10 << 20, "2gb": 2 << 30, "1t": 1 << 40} {
+		if n, err := ParseSize(s); err != nil || n != size {
+			t.Fatalf("%s: expected %d, got %d, %v", s, size, n, err)
+		}
+	}
+	for _, s := range []string{"", "0", "-1k", "k", "1x"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Fatalf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestSubdirDriver(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "subdirs.json")
+	mountpoint := t.TempDir()
+	p := &mountpointDriver{Driver: &testPlugin{}, mountpoint: mountpoint}
+	d, err := NewSubdirDriver(p, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []map[string]string{
+		{"subdir": "name"},
+		{"subdir-label": "web"},
+		{"subdir": "label"},
+		{"subdir": "id", "subdir-label": "web"},
+		{"subdir": "label", "subdir-label": "../web"},
+		{"subdir": "id", "subdir-retention": "forever"},
+	} {
+		if err := d.Create(&CreateRequest{Name: "foo", Options: opts}); !sdk.IsInvalidArgument(err) {
+			t.Fatalf("%v: expected an invalid argument, got %v", opts, err)
+		}
+	}
+	if err := d.Create(&CreateRequest{Name: "foo", Options: map[string]string{"subdir": "id", "size": "1g"}}); err != nil {
+		t.Fatal(err)
+	}
+	if opts := p.Driver.(*testPlugin).lastCreate.Options; len(opts) != 1 || opts["size"] != "1g" {
+		t.Fatalf("expected the options of the driver only, got %v", opts)
+	}
+	if err := d.Create(&CreateRequest{Name: "bar", Options: map[string]string{"subdir": "label", "subdir-label": "web"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create(&CreateRequest{Name: "baz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// volumes isolated by ID mount a subdirectory per mount
+	for _, id := range []string{"c1", "c2"} {
+		res, err := d.Mount(&MountRequest{Name: "foo", ID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Mountpoint != filepath.Join(mountpoint, id) {
+			t.Fatalf("%s: unexpected mountpoint %s", id, res.Mountpoint)
+		}
+		if err := os.WriteFile(filepath.Join(res.Mountpoint, "data"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Mount(&MountRequest{Name: "foo", ID: ".."}); !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument mounting with an ID escaping the volume, got %v", err)
+	}
+	get, err := d.Get(&GetRequest{Name: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get.Volume.Mountpoint != mountpoint || fmt.Sprint(get.Volume.Status) != "map[Isolation:id SubMounts:map[c1:c1 c2:c2]]" {
+		t.Fatalf("unexpected volume %+v", get.Volume)
+	}
+	if err := d.Remove(&RemoveRequest{Name: "foo"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict removing a mounted volume, got %v", err)
+	}
+
+	// volumes isolated by label share their subdirectory
+	for _, id := range []string{"c3", "c4"} {
+		if res, err := d.Mount(&MountRequest{Name: "bar", ID: id}); err != nil || res.Mountpoint != filepath.Join(mountpoint, "web") {
+			t.Fatalf("%s: unexpected mount %+v, %v", id, res, err)
+		}
+	}
+	if res, err := d.Mount(&MountRequest{Name: "baz", ID: "c5"}); err != nil || res.Mountpoint != mountpoint {
+		t.Fatalf("expected the whole volume without isolation, got %+v, %v", res, err)
+	}
+
+	// the mounts are restored across restarts
+	d, err = NewSubdirDriver(p, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Unmount(&UnmountRequest{Name: "foo", ID: "c3"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found unmounting an unknown mount, got %v", err)
+	}
+	if err := d.Unmount(&UnmountRequest{Name: "foo", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(mountpoint, "c1")); !os.IsNotExist(err) {
+		t.Fatalf("expected the subdirectory of the mount to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mountpoint, "c2", "data")); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"c2", "c3", "c4"} {
+		name := map[string]string{"c2": "foo", "c3": "bar", "c4": "bar"}[id]
+		if err := d.Unmount(&UnmountRequest{Name: name, ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(mountpoint, "web")); err != nil {
+		t.Fatalf("expected the subdirectory of the label to be kept, got %v", err)
+	}
+	if p.unmount != 4 {
+		t.Fatalf("expected unmount 4, got %d", p.unmount)
+	}
+	if err := d.Remove(&RemoveRequest{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// subdirectories replaced by links are refused
+	if err := os.RemoveAll(filepath.Join(mountpoint, "web")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/", filepath.Join(mountpoint, "web")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "bar", ID: "c6"}); err == nil {
+		t.Fatal("expected an error mounting a link")
+	}
+	if p.unmount != 5 {
+		t.Fatalf("expected the refused mount to be unmounted, got unmount %d", p.unmount)
+	}
+
+	// subdirectories are only removed when empty with subdir-retention=delete-empty
+	if err := d.Create(&CreateRequest{Name: "qux", Options: map[string]string{"subdir": "id", "subdir-retention": "delete-empty"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"c7", "c8"} {
+		res, err := d.Mount(&MountRequest{Name: "qux", ID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == "c7" {
+			if err := os.WriteFile(filepath.Join(res.Mountpoint, "data"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := d.Unmount(&UnmountRequest{Name: "qux", ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(mountpoint, "c7", "data")); err != nil {
+		t.Fatalf("expected the non-empty subdirectory to be kept, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mountpoint, "c8")); !os.IsNotExist(err) {
+		t.Fatalf("expected the empty subdirectory to be removed, got %v", err)
+	}
+
+	// subdirectories created by mounts failing to be saved are removed
+	if err := os.Remove(state); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(state, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "qux", ID: "c9"}); err == nil {
+		t.Fatal("expected an error saving the mount")
+	}
+	if _, err := os.Stat(filepath.Join(mountpoint, "c9")); !os.IsNotExist(err) {
+		t.Fatalf("expected the subdirectory of the failed mount to be removed, got %v", err)
+	}
+}
+
+func TestUsageDriver(t *testing.T) {
+	mountpoint := t.TempDir()
+	if err := os.WriteFile(filepath.Join(mountpoint, "data"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &mountpointDriver{Driver: &testPlugin{volumes: []string{"foo"}}, mountpoint: mountpoint}
+	var (
+		mu     sync.Mutex
+		events []UsageEvent
+	)
+	d, err := NewUsageDriver(p, filepath.Join(t.TempDir(), "quotas.json"), UsageOptions{
+		Interval:    time.Hour,
+		Concurrency: 1,
+		SoftLimits:  Limits{Bytes: 1024},
+		HardLimits:  Limits{Bytes: 4096, Inodes: 10},
+		Events: func(e UsageEvent) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// wait for the first scan, the next one is in an hour
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if _, _, ok := d.Usage("foo"); ok {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("timeout waiting for the first scan")
+		}
+	}
+
+	scan := func(size int) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(mountpoint, "data"), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Scan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, state, ok := d.Usage("foo")
+	if !ok || usage.Bytes != 100 || usage.Inodes != 2 || state != QuotaOK {
+		t.Fatalf("unexpected usage %+v, %s, %v", usage, state, ok)
+	}
+	get, err := d.Get(&GetRequest{Name: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get.Volume.Status["DiskUsage"].(Usage).Bytes != 100 || get.Volume.Status["QuotaState"] != "ok" {
+		t.Fatalf("unexpected status %v", get.Volume.Status)
+	}
+	if list, err := d.List(); err != nil || list.Volumes[0].Status["QuotaState"] != "ok" {
+		t.Fatalf("unexpected list %+v, %v", list, err)
+	}
+
+	scan(2048)
+	if _, err := d.Mount(&MountRequest{Name: "foo", ID: "c1"}); err != nil {
+		t.Fatalf("expected a volume exceeding its soft limits to mount, got %v", err)
+	}
+	scan(8192)
+	if _, err := d.Mount(&MountRequest{Name: "foo", ID: "c2"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict mounting a volume exceeding its hard limits, got %v", err)
+	}
+	scan(0)
+	if _, err := d.Mount(&MountRequest{Name: "foo", ID: "c2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	var states []string
+	for _, e := range events {
+		states = append(states, fmt.Sprintf("%s->%s", e.Previous, e.State))
+	}
+	mu.Unlock()
+	if s := strings.Join(states, ","); s != "ok->soft limit exceeded,soft limit exceeded->hard limit exceeded,hard limit exceeded->ok" {
+		t.Fatalf("unexpected events %s", s)
+	}
+
+	if err := d.Remove(&RemoveRequest{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := d.Usage("foo"); ok {
+		t.Fatal("expected the usage of a removed volume to be forgotten")
+	}
+	// scans ending after the removal don't measure the volume again
+	d.scanVolume(context.Background(), Volume{Name: "foo", Mountpoint: mountpoint})
+	if _, _, ok := d.Usage("foo"); ok {
+		t.Fatal("expected the usage of a volume removed while scanned to be dropped")
+	}
+
+	// volumes created with quota options have their own limits
+	for _, opts := range []map[string]string{
+		{"quota-hard-size": "0"},
+		{"quota-soft-inodes": "-1"},
+	} {
+		if err := d.Create(&CreateRequest{Name: "foo", Options: opts}); !sdk.IsInvalidArgument(err) {
+			t.Fatalf("%v: expected an invalid argument, got %v", opts, err)
+		}
+	}
+	if err := d.Create(&CreateRequest{Name: "foo", Options: map[string]string{"quota-hard-size": "16k", "size": "1g"}}); err != nil {
+		t.Fatal(err)
+	}
+	if opts := p.Driver.(*testPlugin).lastCreate.Options; len(opts) != 1 || opts["size"] != "1g" {
+		t.Fatalf("expected the options of the driver only, got %v", opts)
+	}
+	scan(8192)
+	if _, state, _ := d.Usage("foo"); state != QuotaSoftExceeded {
+		t.Fatalf("expected the soft limit exceeded within the hard limit of the volume, got %s", state)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "foo", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUsageDriverNilResponses(t *testing.T) {
+	d, err := NewUsageDriver(&nilDriver{}, filepath.Join(t.TempDir(), "quotas.json"), UsageOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Scan(context.Background()); err == nil {
+		t.Fatal("expected an error scanning volumes listed without response")
+	}
+
+	d, err = NewUsageDriver(&listedNilDriver{}, filepath.Join(t.TempDir(), "quotas.json"), UsageOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := d.Usage("foo"); ok {
+		t.Fatal("expected a volume without path not to be scanned")
+	}
+}
+
+// listedNilDriver lists a volume without mountpoint, and replies to the
+// other requests without response.
+type listedNilDriver struct {
+	nilDriver
+}
+
+func (d *listedNilDriver) List() (*ListResponse, error) {
+	return &ListResponse{Volumes: []*Volume{{Name: "foo"}}}, nil
+}
+
+// dirDriver stores every volume in a directory of root.
+type dirDriver struct {
+	Driver
+	root string
+}
+
+func (d *dirDriver) Create(req *CreateRequest) error {
+	if len(req.Options) > 0 {
+		return sdk.InvalidArgument(fmt.Errorf("unexpected options %v", req.Options))
+	}
+	return os.Mkdir(filepath.Join(d.root, req.Name), 0755)
+}
+
+func (d *dirDriver) Remove(req *RemoveRequest) error {
+	return os.RemoveAll(filepath.Join(d.root, req.Name))
+}
+
+func (d *dirDriver) Get(req *GetRequest) (*GetResponse, error) {
+	p, err := d.Path(&PathRequest{Name: req.Name})
+	if err != nil {
+		return nil, err
+	}
+	return &GetResponse{Volume: &Volume{Name: req.Name, Mountpoint: p.Mountpoint}}, nil
+}
+
+func (d *dirDriver) Path(req *PathRequest) (*PathResponse, error) {
+	dir := filepath.Join(d.root, req.Name)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, sdk.NotFound(err)
+	}
+	return &PathResponse{Mountpoint: dir}, nil
+}
+
+func (d *dirDriver) Mount(req *MountRequest) (*MountResponse, error) {
+	p, err := d.Path(&PathRequest{Name: req.Name})
+	if err != nil {
+		return nil, err
+	}
+	return &MountResponse{Mountpoint: p.Mountpoint}, nil
+}
+
+func (d *dirDriver) Unmount(req *UnmountRequest) error {
+	return nil
+}
+
+func TestSnapshotDriver(t *testing.T) {
+	root, snapshots := t.TempDir(), t.TempDir()
+	p := &dirDriver{root: root}
+	d, err := NewSnapshotDriver(p, NewCopySnapshotter(p, snapshots), filepath.Join(t.TempDir(), "snapshots.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Create(&CreateRequest{Name: "src"}); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), []byte("v1"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir", "file"), os.ModeSetuid|0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir"), os.ModeSticky|0500); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []map[string]string{
+		{"snapshot": "s1"},
+		{"from": "clone"},
+		{"from": "src", "snapshot": "../s1"},
+	} {
+		if err := d.Create(&CreateRequest{Name: "clone", Options: opts}); !sdk.IsInvalidArgument(err) {
+			t.Fatalf("%v: expected an invalid argument, got %v", opts, err)
+		}
+	}
+	if err := d.Create(&CreateRequest{Name: "clone", Options: map[string]string{"from": "missing"}}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found cloning a missing volume, got %v", err)
+	}
+	if err := d.Create(&CreateRequest{Name: "src", Options: map[string]string{"from": "other"}}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict cloning to an existing volume, got %v", err)
+	}
+
+	// clones of snapshots have the content of the volume when snapshotted
+	if err := d.Create(&CreateRequest{Name: "snap", Options: map[string]string{"from": "src", "snapshot": "s1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), []byte("v2"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create(&CreateRequest{Name: "copy", Options: map[string]string{"from": "src"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create(&CreateRequest{Name: "snap2", Options: map[string]string{"from": "src", "snapshot": "s1"}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"snap": "v1", "snap2": "v1", "copy": "v2"} {
+		if b, err := os.ReadFile(filepath.Join(root, name, "link")); err != nil || string(b) != content {
+			t.Fatalf("%s: expected %s, got %q, %v", name, content, b, err)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(root, "snap", "dir")); err != nil || fi.Mode() != os.ModeDir|os.ModeSticky|0500 {
+		t.Fatalf("expected the mode of the directory to be copied, got %v, %v", fi, err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "snap", "dir", "file")); err != nil || fi.Mode() != os.ModeSetuid|0750 {
+		t.Fatalf("expected the mode of the file to be copied, got %v, %v", fi, err)
+	}
+
+	get, err := d.Get(&GetRequest{Name: "src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(get.Volume.Status); s != "map[Clones:[copy snap snap2] Snapshots:[s1]]" {
+		t.Fatalf("unexpected status %s", s)
+	}
+	if get, err := d.Get(&GetRequest{Name: "snap"}); err != nil || get.Volume.Status["ClonedFrom"] != "src@s1" {
+		t.Fatalf("unexpected clone %+v, %v", get, err)
+	}
+
+	// volumes can't be removed while cloned
+	if err := d.Remove(&RemoveRequest{Name: "src"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict removing a cloned volume, got %v", err)
+	}
+	for _, name := range []string{"snap", "snap2", "copy", "src"} {
+		if err := d.Remove(&RemoveRequest{Name: name}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	for _, dir := range []string{root, snapshots} {
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+			t.Fatalf("expected no volumes or snapshots, got %v, %v", entries, err)
+		}
+	}
+}
+
+// tarArchive returns a tar archive of the entries of hdrs, with the
+// content of their files.
+func tarArchive(t *testing.T, hdrs ...*tar.Header) []byte {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, hdr := range hdrs {
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		content := hdr.Linkname
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Linkname, hdr.Size = "", int64(len(content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			io.WriteString(tw, content)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExportDriver(t *testing.T) {
+	root := t.TempDir()
+	d, err := NewExportDriver(&dirDriver{root: root}, filepath.Join(t.TempDir(), "mounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(d)
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	if err := c.Create(&CreateRequest{Name: "src"}); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), []byte("v1"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir", "file"), os.ModeSetgid|0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir"), os.ModeSticky|0500); err != nil {
+		t.Fatal(err)
+	}
+
+	// strict exports are refused while mounted
+	if _, err := c.Mount(&MountRequest{Name: "src", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Export(&ExportRequest{Name: "src"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict exporting a mounted volume, got %v", err)
+	}
+	if _, err := c.Export(&ExportRequest{Name: "src", Consistency: "eventual"}); !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+	r, err := c.Export(&ExportRequest{Name: "src", Consistency: ConsistencyNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if err := c.Unmount(&UnmountRequest{Name: "src", ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = c.Export(&ExportRequest{Name: "src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Create(&CreateRequest{Name: "dst"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Import(&ImportRequest{Name: "dst", Archive: bytes.NewReader(archive)}); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(root, "dst")
+	if b, err := os.ReadFile(filepath.Join(dst, "link")); err != nil || string(b) != "v1" {
+		t.Fatalf("expected v1, got %q, %v", b, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "dir")); err != nil || fi.Mode() != os.ModeDir|os.ModeSticky|0500 {
+		t.Fatalf("expected the mode of the directory to be restored, got %v, %v", fi, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "dir", "file")); err != nil || fi.Mode() != os.ModeSetgid|0750 {
+		t.Fatalf("expected the mode of the file to be restored, got %v, %v", fi, err)
+	}
+	if err := c.Import(&ImportRequest{Name: "dst", Archive: bytes.NewReader(archive)}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict importing in a volume not empty, got %v", err)
+	}
+	if err := c.Import(&ImportRequest{Name: "missing", Archive: bytes.NewReader(archive)}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found importing in a missing volume, got %v", err)
+	}
+
+	// archives can't write out of the volume, failed imports are emptied
+	if err := c.Create(&CreateRequest{Name: "evil"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, hdrs := range [][]*tar.Header{
+		{{Name: "../escape", Typeflag: tar.TypeReg}},
+		{{Name: "ok", Typeflag: tar.TypeReg}, {Name: "/abs", Typeflag: tar.TypeReg}},
+		{{Name: "up", Typeflag: tar.TypeSymlink, Linkname: ".."}, {Name: "up/escape", Typeflag: tar.TypeReg}},
+		{{Name: "up", Typeflag: tar.TypeSymlink, Linkname: ".."}, {Name: "hard", Typeflag: tar.TypeLink, Linkname: "up/src/link"}},
+		{{Name: "fifo", Typeflag: tar.TypeFifo}},
+	} {
+		err := c.Import(&ImportRequest{Name: "evil", Archive: bytes.NewReader(tarArchive(t, hdrs...))})
+		if !sdk.IsInvalidArgument(err) {
+			t.Fatalf("%s: expected an invalid argument, got %v", hdrs[len(hdrs)-1].Name, err)
+		}
+		if entries, err := os.ReadDir(filepath.Join(root, "evil")); err != nil || len(entries) != 0 {
+			t.Fatalf("expected an empty volume, got %v, %v", entries, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(root, "escape")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file out of the volume, got %v", err)
+	}
+
+	// volumes can't be mounted or removed while strictly exported
+	r, err = d.Export(&ExportRequest{Name: "src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Mount(&MountRequest{Name: "src", ID: "c2"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict mounting an exported volume, got %v", err)
+	}
+	if err := d.Remove(&RemoveRequest{Name: "src"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict removing an exported volume, got %v", err)
+	}
+	r.Close()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		_, err := d.Mount(&MountRequest{Name: "src", ID: "c2"})
+		if err == nil {
+			break
+		}
+		if !sdk.IsConflict(err) || time.Now().After(deadline) {
+			t.Fatalf("expected the export to end when closed, got %v", err)
+		}
+	}
+}
+
+func TestExportNotImplemented(t *testing.T) {
+	h := NewHandler(&testPlugin{})
+	l := sockets.NewInmemSocket("test", 0)
+	go h.Serve(l)
+	defer l.Close()
+	c := NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	if _, err := c.Export(&ExportRequest{Name: "foo"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := c.Import(&ImportRequest{Name: "foo", Archive: strings.NewReader("")}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
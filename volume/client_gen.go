@@ -0,0 +1,79 @@
+// Code generated by protocolgen. DO NOT EDIT.
+
+package volume
+
+import "github.com/docker/go-plugins-helpers/sdk"
+
+// Client calls the methods of a plugin through an sdk.Client. It implements
+// Driver, so it can be used wherever a local driver is expected.
+type Client struct {
+	client *sdk.Client
+}
+
+// NewClient creates a Client sending its requests through c.
+func NewClient(c *sdk.Client) *Client {
+	return &Client{client: c}
+}
+
+var _ Driver = (*Client)(nil)
+
+// Create calls the Create method of the plugin.
+func (c *Client) Create(req *CreateRequest) error {
+	return c.client.Call(createPath, req, nil)
+}
+
+// List calls the List method of the plugin.
+func (c *Client) List() (*ListResponse, error) {
+	res := &ListResponse{}
+	if err := c.client.Call(listPath, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Get calls the Get method of the plugin.
+func (c *Client) Get(req *GetRequest) (*GetResponse, error) {
+	res := &GetResponse{}
+	if err := c.client.Call(getPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Remove calls the Remove method of the plugin.
+func (c *Client) Remove(req *RemoveRequest) error {
+	return c.client.Call(removePath, req, nil)
+}
+
+// Path calls the Path method of the plugin.
+func (c *Client) Path(req *PathRequest) (*PathResponse, error) {
+	res := &PathResponse{}
+	if err := c.client.Call(hostVirtualPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Mount calls the Mount method of the plugin.
+func (c *Client) Mount(req *MountRequest) (*MountResponse, error) {
+	res := &MountResponse{}
+	if err := c.client.Call(mountPath, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Unmount calls the Unmount method of the plugin.
+func (c *Client) Unmount(req *UnmountRequest) error {
+	return c.client.Call(unmountPath, req, nil)
+}
+
+// Capabilities calls the Capabilities method of the plugin.
+// Errors are reported as an empty response.
+func (c *Client) Capabilities() *CapabilitiesResponse {
+	res := &CapabilitiesResponse{}
+	if err := c.client.Call(capabilitiesPath, nil, res); err != nil {
+		return &CapabilitiesResponse{}
+	}
+	return res
+}
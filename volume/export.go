@@ -0,0 +1,321 @@
+package volume
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/store"
+)
+
+// The extension routes are not part of the protocol of the docker daemon,
+// which never calls them.
+const (
+	exportPath = "/VolumeDriverExt.Export"
+	importPath = "/VolumeDriverExt.Import"
+)
+
+// Consistency modes of ExportRequest.
+const (
+	// ConsistencyStrict refuses to export volumes mounted read-write, and
+	// to mount volumes while they are exported.
+	ConsistencyStrict = "strict"
+	// ConsistencyNone exports volumes even while they are written to.
+	ConsistencyNone = "none"
+)
+
+// ExportRequest structure for a volume export request
+type ExportRequest struct {
+	Name string
+	// Consistency is the consistency mode of the export, ConsistencyStrict
+	// when empty.
+	Consistency string `json:",omitempty"`
+
+	// Unknown holds the undeclared fields, see sdk.DecodeCollect.
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+// ImportRequest structure for a volume import request. Name is sent as a
+// query parameter and Archive as the body of the request.
+type ImportRequest struct {
+	Name string
+	// Archive is the tar archive restored in the volume. The driver must
+	// read it before replying.
+	Archive io.Reader
+}
+
+func (req *ImportRequest) decodeHTTP(r *http.Request) error {
+	req.Name, req.Archive = r.URL.Query().Get("name"), r.Body
+	if req.Name == "" {
+		return errors.New("missing volume name")
+	}
+	return nil
+}
+
+func (req *ImportRequest) encodeHTTP() (url.Values, io.Reader) {
+	return url.Values{"name": {req.Name}}, req.Archive
+}
+
+// Exporter is implemented by drivers backing up their volumes. The Handler
+// of a driver implementing it also serves the /VolumeDriverExt.Export and
+// /VolumeDriverExt.Import extension routes. The other wrappers of this
+// package, such as NewRefCountDriver, don't forward it, the driver passed
+// to NewHandler must be the Exporter.
+type Exporter interface {
+	// Export returns the content of a volume as a tar archive.
+	Export(*ExportRequest) (io.ReadCloser, error)
+	// Import restores the content of a volume from a tar archive.
+	Import(*ImportRequest) error
+}
+
+func (h *Handler) initExportMux(e Exporter) {
+	h.HandleFunc(exportPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ExportRequest{}
+		if err := sdk.DecodeRequest(w, r, req); err != nil {
+			return
+		}
+		res, err := e.Export(req)
+		if err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.StreamResponseContext(r.Context(), w, res)
+	})
+	h.HandleFunc(importPath, func(w http.ResponseWriter, r *http.Request) {
+		req := &ImportRequest{}
+		if err := req.decodeHTTP(r); err != nil {
+			sdk.EncodeError(w, sdk.InvalidArgument(err))
+			return
+		}
+		if err := e.Import(req); err != nil {
+			sdk.EncodeError(w, err)
+			return
+		}
+		sdk.EncodeResponse(w, struct{}{}, false)
+	})
+}
+
+var _ Exporter = (*Client)(nil)
+
+// Export calls the Export extension method of the plugin. Plugins not
+// implementing Exporter reply with a not found error.
+func (c *Client) Export(req *ExportRequest) (io.ReadCloser, error) {
+	return c.client.Stream(exportPath, req)
+}
+
+// Import calls the Import extension method of the plugin. Plugins not
+// implementing Exporter reply with a not found error.
+func (c *Client) Import(req *ImportRequest) error {
+	query, body := req.encodeHTTP()
+	return c.client.Send(importPath+"?"+query.Encode(), body, nil)
+}
+
+// ExportDriver is a Driver exporting its volumes, see NewExportDriver.
+type ExportDriver interface {
+	Driver
+	Exporter
+}
+
+// exportDriver exports the directories of volumes as tar archives.
+type exportDriver struct {
+	Driver
+
+	// mu guards exports and imports, and serializes them with the mounts,
+	// so that strict exports never see a volume mounted.
+	mu     sync.Mutex
+	mounts *store.Store[[]string]
+	// exports are the numbers of strict exports in progress by volume.
+	exports map[string]int
+	// imports are the volumes being imported.
+	imports map[string]bool
+}
+
+// NewExportDriver returns a Driver exporting and importing the volumes of
+// driver as tar archives of the files under the path it returns, for
+// drivers storing volumes in directories such as volume/local.
+//
+// The volume protocol doesn't tell whether mounts are read-only, so every
+// mount of a volume counts as read-write: strict exports are refused while
+// the volume is mounted, and new mounts while it is strictly exported.
+// Volumes are only imported while unmounted and empty, and are emptied
+// again when the import fails. The mounts are saved to a store at path.
+//
+// Files are archived with their mode, owner and modification time, but
+// files linked more than once are archived at every link, and files other
+// than directories, regular files and symbolic links are an error.
+//
+// The other wrappers of this package return drivers without Export and
+// Import, so NewExportDriver wraps them last, as the driver of the Handler.
+func NewExportDriver(driver Driver, path string) (ExportDriver, error) {
+	mounts, err := store.Open[[]string](path, store.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &exportDriver{
+		Driver:  driver,
+		mounts:  mounts,
+		exports: make(map[string]int),
+		imports: make(map[string]bool),
+	}, nil
+}
+
+// busy returns an error when the volume name is exported or imported.
+func (d *exportDriver) busy(name string) error {
+	if d.exports[name] > 0 {
+		return sdk.Conflict(fmt.Errorf("volume %s is being exported", name))
+	}
+	if d.imports[name] {
+		return sdk.Conflict(fmt.Errorf("volume %s is being imported", name))
+	}
+	return nil
+}
+
+func (d *exportDriver) Mount(req *MountRequest) (*MountResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.busy(req.Name); err != nil {
+		return nil, err
+	}
+	res, err := d.Driver.Mount(req)
+	if err != nil {
+		return nil, err
+	}
+	ids, _ := d.mounts.Get(req.Name)
+	if contains(ids, req.ID) {
+		return res, nil
+	}
+	// the stored IDs may be read concurrently, appending copies them
+	ids = append(ids[:len(ids):len(ids)], req.ID)
+	sort.Strings(ids)
+	if err := d.mounts.Put(req.Name, ids); err != nil {
+		d.Driver.Unmount(&UnmountRequest{Name: req.Name, ID: req.ID})
+		return nil, err
+	}
+	return res, nil
+}
+
+func (d *exportDriver) Unmount(req *UnmountRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.Driver.Unmount(req); err != nil {
+		return err
+	}
+	ids, _ := d.mounts.Get(req.Name)
+	var rest []string
+	for _, id := range ids {
+		if id != req.ID {
+			rest = append(rest, id)
+		}
+	}
+	switch {
+	case len(rest) == len(ids):
+		return nil
+	case len(rest) == 0:
+		return d.mounts.Delete(req.Name)
+	}
+	return d.mounts.Put(req.Name, rest)
+}
+
+func (d *exportDriver) Remove(req *RemoveRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.busy(req.Name); err != nil {
+		return err
+	}
+	if err := d.Driver.Remove(req); err != nil {
+		return err
+	}
+	return d.mounts.Delete(req.Name)
+}
+
+func (d *exportDriver) Export(req *ExportRequest) (io.ReadCloser, error) {
+	strict := true
+	switch req.Consistency {
+	case "", ConsistencyStrict:
+	case ConsistencyNone:
+		strict = false
+	default:
+		return nil, sdk.InvalidArgument(fmt.Errorf("consistency %q is not one of %s, %s", req.Consistency, ConsistencyStrict, ConsistencyNone))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ids, _ := d.mounts.Get(req.Name); strict && len(ids) > 0 {
+		return nil, sdk.Conflict(fmt.Errorf("volume %s is mounted by %d containers, export it with consistency %s", req.Name, len(ids), ConsistencyNone))
+	}
+	if d.imports[req.Name] {
+		return nil, sdk.Conflict(fmt.Errorf("volume %s is being imported", req.Name))
+	}
+	dir, err := volumePath(d.Driver, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if strict {
+		d.exports[req.Name]++
+	}
+	r, w := io.Pipe()
+	go func() {
+		// closing r ends the archive with an error at its next write
+		w.CloseWithError(writeTar(w, dir))
+		if strict {
+			d.mu.Lock()
+			if d.exports[req.Name]--; d.exports[req.Name] == 0 {
+				delete(d.exports, req.Name)
+			}
+			d.mu.Unlock()
+		}
+	}()
+	return r, nil
+}
+
+func (d *exportDriver) Import(req *ImportRequest) error {
+	d.mu.Lock()
+	if ids, _ := d.mounts.Get(req.Name); len(ids) > 0 {
+		d.mu.Unlock()
+		return sdk.Conflict(fmt.Errorf("volume %s is mounted by %d containers", req.Name, len(ids)))
+	}
+	if err := d.busy(req.Name); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	dir, err := volumePath(d.Driver, req.Name)
+	if err == nil {
+		err = checkEmpty(dir)
+	}
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	d.imports[req.Name] = true
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.imports, req.Name)
+		d.mu.Unlock()
+	}()
+	if err := readTar(req.Archive, dir); err != nil {
+		return errors.Join(err, empty(dir))
+	}
+	return nil
+}
+
+// checkEmpty returns a conflict when the directory dir isn't empty.
+func checkEmpty(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return sdk.Conflict(fmt.Errorf("volume directory %s is not empty", dir))
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package volume
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// writeTar writes the files of the directory dir to w as a tar archive,
+// with paths relative to dir.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		switch {
+		case fi.IsDir(), fi.Mode().IsRegular():
+		case fi.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: unsupported file type %s", path, fi.Mode().Type())
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts the tar archive of r in the directory dir. Invalid
+// archives and entries out of dir are invalid arguments.
+func readTar(r io.Reader, dir string) error {
+	var dirs []*tar.Header
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sdk.InvalidArgument(err)
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return sdk.InvalidArgument(fmt.Errorf("%s is out of the volume", hdr.Name))
+		}
+		if err := checkParents(dir, name, true); err != nil {
+			return err
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, 0700); err != nil {
+				if fi, serr := os.Lstat(target); serr != nil || !fi.IsDir() {
+					return err
+				}
+			}
+			dirs = append(dirs, hdr)
+			continue
+		case tar.TypeReg:
+			if err := extractFile(target, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// links are extracted as is, never followed
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			if err := lchown(target, hdr.Uid, hdr.Gid); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeLink:
+			old := filepath.Clean(filepath.FromSlash(hdr.Linkname))
+			if !filepath.IsLocal(old) {
+				return sdk.InvalidArgument(fmt.Errorf("%s links out of the volume", hdr.Name))
+			}
+			if err := checkParents(dir, old, false); err != nil {
+				return err
+			}
+			if fi, err := os.Lstat(filepath.Join(dir, old)); err != nil || !fi.Mode().IsRegular() {
+				return sdk.InvalidArgument(fmt.Errorf("%s links to %s, not a file of the archive", hdr.Name, hdr.Linkname))
+			}
+			if err := os.Link(filepath.Join(dir, old), target); err != nil {
+				return err
+			}
+			continue
+		default:
+			return sdk.InvalidArgument(fmt.Errorf("%s: unsupported tar entry type %q", hdr.Name, hdr.Typeflag))
+		}
+		if err := setAttrs(target, hdr); err != nil {
+			return err
+		}
+	}
+
+	// the mode and times of directories are set once their files are
+	// extracted, children first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		hdr := dirs[i]
+		if err := setAttrs(filepath.Join(dir, filepath.Clean(filepath.FromSlash(hdr.Name))), hdr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParents checks that the parents of the file name under dir are
+// directories, and not symbolic links which could lead out of dir. Missing
+// parents are created when create is set.
+func checkParents(dir, name string, create bool) error {
+	parent := filepath.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	path := dir
+	for _, elem := range strings.Split(parent, string(filepath.Separator)) {
+		path = filepath.Join(path, elem)
+		fi, err := os.Lstat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && create:
+			if err := os.Mkdir(path, 0755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !fi.IsDir():
+			return sdk.InvalidArgument(fmt.Errorf("%s is not a directory", path))
+		}
+	}
+	return nil
+}
+
+func extractFile(target string, r io.Reader) error {
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// setAttrs sets the owner, mode and modification time of hdr to path.
+func setAttrs(path string, hdr *tar.Header) error {
+	if err := lchown(path, hdr.Uid, hdr.Gid); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, fileMode(hdr.FileInfo().Mode())); err != nil {
+		return err
+	}
+	return os.Chtimes(path, hdr.ModTime, hdr.ModTime)
+}
+
+// empty removes the files of the directory dir.
+func empty(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,335 @@
+// Package local implements a volume driver storing volumes in directories
+// under a root directory on the host, as a starting point for plugins.
+//
+// The options of the driver, set with docker volume create --opt, are
+// listed by OptionsSchema.Help:
+//
+//	gid=<int>                 group of the volume directory, the group of the plugin by default
+//	labels=<key>=<value>,...  labels describing the volume
+//	mode=<mode>               permissions of the volume directory in octal (default 0755)
+//	size=<size>               soft limit of the size of the volume, unlimited by default
+//	uid=<int>                 owner of the volume directory, the user of the plugin by default
+//
+// The size limit isn't enforced, volumes exceeding it are reported in the
+// status of the volume, with its usage. The usage is measured by walking the
+// volume, at most every 10 seconds.
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/store"
+	"github.com/docker/go-plugins-helpers/volume"
+)
+
+const (
+	// volumesDir is the directory of the volumes under the root, where
+	// each volume has a directory holding its data directory.
+	volumesDir = "volumes"
+	dataDir    = "_data"
+	// metadataFile is the store of the volumes under the root.
+	metadataFile = "metadata.json"
+)
+
+// usageTTL is how long the usage of a volume measured by Get is reported by
+// the next calls, without walking the volume again.
+const usageTTL = 10 * time.Second
+
+// validName matches the volume names accepted by the daemon.
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// Options are the options of the volumes.
+type Options struct {
+	UID    *uint32           `opt:"uid" help:"owner of the volume directory, the user of the plugin by default"`
+	GID    *uint32           `opt:"gid" help:"group of the volume directory, the group of the plugin by default"`
+	Mode   os.FileMode       `opt:"mode" default:"0755" help:"permissions of the volume directory in octal"`
+	Size   volume.Size       `opt:"size" help:"soft limit of the size of the volume, unlimited by default"`
+	Labels map[string]string `opt:"labels" help:"labels describing the volume"`
+}
+
+// OptionsSchema is the schema of the options of the volumes, listing them
+// with its Help method.
+var OptionsSchema = volume.MustOptionsSchema(Options{})
+
+// metadata describes a volume.
+type metadata struct {
+	CreatedAt time.Time
+	Options
+	// Mounts are the IDs of the active mounts of the volume.
+	Mounts []string `json:",omitempty"`
+}
+
+// Driver is a volume.Driver storing volumes in directories under its root
+// directory.
+type Driver struct {
+	root string
+
+	// mu serializes the changes to the volume directories with the ones
+	// to the store.
+	mu      sync.Mutex
+	volumes *store.Store[metadata]
+
+	// usageMu guards usages, the last usage measured of the volumes.
+	usageMu sync.Mutex
+	usages  map[string]*usage
+}
+
+// usage is the size of a volume, measured at measuredAt.
+type usage struct {
+	// mu serializes the measures of the volume.
+	mu         sync.Mutex
+	size       int64
+	measuredAt time.Time
+}
+
+// NewDriver returns a Driver storing volumes under root, along with their
+// metadata.
+func NewDriver(root string) (*Driver, error) {
+	if err := os.MkdirAll(filepath.Join(root, volumesDir), 0700); err != nil {
+		return nil, err
+	}
+	volumes, err := store.Open[metadata](filepath.Join(root, metadataFile), store.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &Driver{root: root, volumes: volumes, usages: make(map[string]*usage)}, nil
+}
+
+// mountpoint returns the data directory of the volume name.
+func (d *Driver) mountpoint(name string) string {
+	return filepath.Join(d.root, volumesDir, name, dataDir)
+}
+
+// get returns the metadata of the volume name, or a not found error.
+func (d *Driver) get(name string) (metadata, error) {
+	m, ok := d.volumes.Get(name)
+	if !ok {
+		return m, sdk.NotFound(fmt.Errorf("no such volume: %s", name))
+	}
+	return m, nil
+}
+
+// Create creates the directory of a volume. Creating an existing volume
+// does nothing, whatever its options.
+func (d *Driver) Create(req *volume.CreateRequest) error {
+	if !validName.MatchString(req.Name) {
+		return sdk.InvalidArgument(fmt.Errorf("invalid volume name %q", req.Name))
+	}
+	var m metadata
+	if err := OptionsSchema.Decode(req.Options, &m.Options); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.volumes.Get(req.Name); ok {
+		return nil
+	}
+	dir := filepath.Join(d.root, volumesDir, req.Name)
+	if err := d.createData(dir, m); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	m.CreatedAt = time.Now().UTC()
+	if err := d.volumes.Put(req.Name, m); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+func (d *Driver) createData(dir string, m metadata) error {
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return err
+	}
+	data := filepath.Join(dir, dataDir)
+	if err := os.Mkdir(data, m.Mode); err != nil {
+		return err
+	}
+	// the mode is applied as is, regardless of the umask
+	if err := os.Chmod(data, m.Mode); err != nil {
+		return err
+	}
+	uid, gid := -1, -1
+	if m.UID != nil {
+		uid = int(*m.UID)
+	}
+	if m.GID != nil {
+		gid = int(*m.GID)
+	}
+	if uid >= 0 || gid >= 0 {
+		return os.Chown(data, uid, gid)
+	}
+	return nil
+}
+
+// Remove removes a volume and its data, unless it is mounted.
+func (d *Driver) Remove(req *volume.RemoveRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.get(req.Name)
+	if err != nil {
+		return err
+	}
+	if len(m.Mounts) > 0 {
+		return sdk.Conflict(fmt.Errorf("volume %s is in use by %d mounts", req.Name, len(m.Mounts)))
+	}
+	if err := os.RemoveAll(filepath.Join(d.root, volumesDir, req.Name)); err != nil {
+		return err
+	}
+	d.usageMu.Lock()
+	delete(d.usages, req.Name)
+	d.usageMu.Unlock()
+	return d.volumes.Delete(req.Name)
+}
+
+// Get returns a volume, with its usage, limit and labels in its status. The
+// usage is measured again once older than usageTTL.
+func (d *Driver) Get(req *volume.GetRequest) (*volume.GetResponse, error) {
+	m, err := d.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	v := d.volume(req.Name, m)
+	size, err := d.usage(req.Name, v.Mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	v.Status = map[string]interface{}{
+		"Usage":  size,
+		"Mounts": len(m.Mounts),
+	}
+	if m.Size > 0 {
+		v.Status["Limit"] = int64(m.Size)
+		v.Status["LimitExceeded"] = size > int64(m.Size)
+	}
+	if len(m.Labels) > 0 {
+		v.Status["Labels"] = m.Labels
+	}
+	return &volume.GetResponse{Volume: v}, nil
+}
+
+func (d *Driver) volume(name string, m metadata) *volume.Volume {
+	return &volume.Volume{
+		Name:       name,
+		Mountpoint: d.mountpoint(name),
+		CreatedAt:  m.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// usage returns the size of the volume name, measured under dir unless its
+// last measure is younger than usageTTL. Concurrent calls for a volume wait
+// for a single measure.
+func (d *Driver) usage(name, dir string) (int64, error) {
+	d.usageMu.Lock()
+	u, ok := d.usages[name]
+	if !ok {
+		u = &usage{}
+		d.usages[name] = u
+	}
+	d.usageMu.Unlock()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.measuredAt.IsZero() && time.Since(u.measuredAt) < usageTTL {
+		return u.size, nil
+	}
+	size, err := diskUsage(dir)
+	if err != nil {
+		return 0, err
+	}
+	u.size, u.measuredAt = size, time.Now()
+	return size, nil
+}
+
+// diskUsage returns the size of the files under dir. The files removed while
+// walking dir are skipped, mounted volumes change as they are walked.
+func diskUsage(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if e.Type().IsRegular() {
+			fi, err := e.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// List returns the volumes, sorted by name, without status.
+func (d *Driver) List() (*volume.ListResponse, error) {
+	var res volume.ListResponse
+	for _, name := range d.volumes.Keys() {
+		if m, ok := d.volumes.Get(name); ok {
+			res.Volumes = append(res.Volumes, d.volume(name, m))
+		}
+	}
+	return &res, nil
+}
+
+// Path returns the data directory of a volume.
+func (d *Driver) Path(req *volume.PathRequest) (*volume.PathResponse, error) {
+	if _, err := d.get(req.Name); err != nil {
+		return nil, err
+	}
+	return &volume.PathResponse{Mountpoint: d.mountpoint(req.Name)}, nil
+}
+
+// Mount records the mount of a volume, and returns its data directory.
+func (d *Driver) Mount(req *volume.MountRequest) (*volume.MountResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if i := sort.SearchStrings(m.Mounts, req.ID); i == len(m.Mounts) || m.Mounts[i] != req.ID {
+		m.Mounts = append(append(append([]string(nil), m.Mounts[:i]...), req.ID), m.Mounts[i:]...)
+		if err := d.volumes.Put(req.Name, m); err != nil {
+			return nil, err
+		}
+	}
+	return &volume.MountResponse{Mountpoint: d.mountpoint(req.Name)}, nil
+}
+
+// Unmount records the end of a mount of a volume.
+func (d *Driver) Unmount(req *volume.UnmountRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.get(req.Name)
+	if err != nil {
+		return err
+	}
+	i := sort.SearchStrings(m.Mounts, req.ID)
+	if i == len(m.Mounts) || m.Mounts[i] != req.ID {
+		return sdk.NotFound(fmt.Errorf("volume %s is not mounted by %s", req.Name, req.ID))
+	}
+	m.Mounts = append(append([]string(nil), m.Mounts[:i]...), m.Mounts[i+1:]...)
+	return d.volumes.Put(req.Name, m)
+}
+
+// Capabilities reports the volumes as local to the host.
+func (d *Driver) Capabilities() *volume.CapabilitiesResponse {
+	return &volume.CapabilitiesResponse{Capabilities: volume.Capability{Scope: "local"}}
+}
@@ -0,0 +1,161 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/volume"
+)
+
+func TestDriver(t *testing.T) {
+	root := t.TempDir()
+	d, err := NewDriver(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := map[string]string{"mode": "0750", "size": "1k", "labels": "env=test, team=storage"}
+	if err := d.Create(&volume.CreateRequest{Name: "data", Options: opts}); err != nil {
+		t.Fatal(err)
+	}
+	// creating an existing volume does nothing
+	if err := d.Create(&volume.CreateRequest{Name: "data"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mountpoint := filepath.Join(root, "volumes", "data", "_data")
+	fi, err := os.Stat(mountpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0750 {
+		t.Fatalf("expected mode 0750, got %v", fi.Mode())
+	}
+	if err := os.WriteFile(filepath.Join(mountpoint, "file"), make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := d.Get(&volume.GetRequest{Name: "data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := res.Volume
+	if v.Mountpoint != mountpoint || v.CreatedAt == "" {
+		t.Fatalf("unexpected volume %+v", v)
+	}
+	if v.Status["Usage"] != int64(2048) || v.Status["Limit"] != int64(1024) || v.Status["LimitExceeded"] != true {
+		t.Fatalf("unexpected status %v", v.Status)
+	}
+	if labels, _ := v.Status["Labels"].(map[string]string); labels["env"] != "test" || labels["team"] != "storage" {
+		t.Fatalf("unexpected labels %v", v.Status["Labels"])
+	}
+
+	// the usage is measured again once older than usageTTL
+	if err := os.WriteFile(filepath.Join(mountpoint, "other"), make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := d.Get(&volume.GetRequest{Name: "data"}); err != nil || res.Volume.Status["Usage"] != int64(2048) {
+		t.Fatalf("expected the usage measured last, got %v, %v", res, err)
+	}
+	d.usages["data"].measuredAt = time.Now().Add(-usageTTL)
+	if res, err := d.Get(&volume.GetRequest{Name: "data"}); err != nil || res.Volume.Status["Usage"] != int64(3072) {
+		t.Fatalf("expected the usage measured again, got %v, %v", res, err)
+	}
+
+	if _, err := d.Mount(&volume.MountRequest{Name: "data", ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := d.Path(&volume.PathRequest{Name: "data"}); err != nil || p.Mountpoint != mountpoint {
+		t.Fatalf("unexpected path %v, %v", p, err)
+	}
+	if err := d.Remove(&volume.RemoveRequest{Name: "data"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict removing a mounted volume, got %v", err)
+	}
+
+	// the volumes and their mounts are restored from the metadata
+	d, err = NewDriver(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.List()
+	if err != nil || len(list.Volumes) != 1 || list.Volumes[0].Name != "data" || list.Volumes[0].Status != nil {
+		t.Fatalf("unexpected list %+v, %v", list, err)
+	}
+	if err := d.Unmount(&volume.UnmountRequest{Name: "data", ID: "b"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found unmounting an unknown mount, got %v", err)
+	}
+	if err := d.Unmount(&volume.UnmountRequest{Name: "data", ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(&volume.RemoveRequest{Name: "data"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "volumes", "data")); !os.IsNotExist(err) {
+		t.Fatalf("expected the volume directory to be removed, got %v", err)
+	}
+	if _, err := d.Get(&volume.GetRequest{Name: "data"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found getting a removed volume, got %v", err)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	d, err := NewDriver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		opts map[string]string
+		err  string
+	}{
+		{"../escape", nil, "invalid volume name"},
+		{"data", map[string]string{"uid": "-1"}, "not a positive integer"},
+		{"data", map[string]string{"mode": "1777"}, "not an octal permission mode"},
+		{"data", map[string]string{"mode": "rwx"}, "invalid option mode"},
+		{"data", map[string]string{"size": "0"}, "not a positive size"},
+		{"data", map[string]string{"labels": "env"}, "not a key=value pair"},
+		{"data", map[string]string{"type": "nfs"}, "supported options are gid, labels, mode, size, uid"},
+	} {
+		err := d.Create(&volume.CreateRequest{Name: tc.name, Options: tc.opts})
+		if !sdk.IsInvalidArgument(err) || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s %v: expected an invalid argument containing %q, got %v", tc.name, tc.opts, tc.err, err)
+		}
+	}
+	if list, _ := d.List(); len(list.Volumes) != 0 {
+		t.Fatalf("expected no volumes, got %+v", list.Volumes)
+	}
+}
+
+func TestRefCountDriver(t *testing.T) {
+	root := t.TempDir()
+	l, err := NewDriver(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := volume.NewRefCountDriver(l, filepath.Join(root, "mounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create(&volume.CreateRequest{Name: "data"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last unmount unmounts the ID of the first mount from the driver
+	for _, id := range []string{"a", "b"} {
+		if _, err := d.Mount(&volume.MountRequest{Name: "data", ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := d.Unmount(&volume.UnmountRequest{Name: "data", ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Remove(&volume.RemoveRequest{Name: "data"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package volume
+
+import (
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// mountpointChecker fails the requests replied with mountpoints the daemon
+// can't reach.
+type mountpointChecker struct {
+	Driver
+	config *sdk.PluginConfig
+}
+
+// CheckMountpoints returns a Driver failing the requests driver replies to
+// with mountpoints outside of the propagated mount of config. The daemon
+// only sees the mounts of managed plugins under their propagated mount.
+// NewHandler checks the mountpoints of managed plugins with it.
+func CheckMountpoints(driver Driver, config *sdk.PluginConfig) Driver {
+	return &mountpointChecker{driver, config}
+}
+
+// check checks mountpoint, which is empty for unmounted volumes.
+func (d *mountpointChecker) check(mountpoint string) error {
+	if mountpoint == "" {
+		return nil
+	}
+	return d.config.CheckMountpoint(mountpoint)
+}
+
+func (d *mountpointChecker) List() (*ListResponse, error) {
+	res, err := d.Driver.List()
+	if err != nil || res == nil {
+		return res, err
+	}
+	for _, v := range res.Volumes {
+		if v == nil {
+			continue
+		}
+		if err := d.check(v.Mountpoint); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (d *mountpointChecker) Get(req *GetRequest) (*GetResponse, error) {
+	res, err := d.Driver.Get(req)
+	if err != nil || res == nil {
+		return res, err
+	}
+	if res.Volume != nil {
+		if err := d.check(res.Volume.Mountpoint); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (d *mountpointChecker) Path(req *PathRequest) (*PathResponse, error) {
+	res, err := d.Driver.Path(req)
+	if err != nil || res == nil {
+		return res, err
+	}
+	if err := d.check(res.Mountpoint); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Mount unmounts the volume again when its mountpoint is rejected, or
+// missing, since the daemon doesn't unmount volumes it failed to mount.
+func (d *mountpointChecker) Mount(req *MountRequest) (*MountResponse, error) {
+	res, err := d.Driver.Mount(req)
+	if err != nil {
+		return nil, err
+	}
+	var mountpoint string
+	if res != nil {
+		mountpoint = res.Mountpoint
+	}
+	if err := d.config.CheckMountpoint(mountpoint); err != nil {
+		d.Driver.Unmount(&UnmountRequest{Name: req.Name, ID: req.ID})
+		return nil, err
+	}
+	return res, nil
+}
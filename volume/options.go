@@ -0,0 +1,226 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+)
+
+// Size is a size in bytes, set by options with an optional k, m, g or t
+// suffix, as powers of 1024.
+type Size = sdk.Size
+
+// ParseSize parses a positive size with an optional k, m, g or t suffix.
+func ParseSize(s string) (Size, error) {
+	return sdk.ParseSize(s)
+}
+
+// OptionsSchema describes the options of the volumes of a driver, set with
+// docker volume create --opt, and decodes them. The options are declared
+// by the fields of a structure, with their opt tag:
+//
+//	type options struct {
+//		Size Size              `opt:"size,required" help:"size of the volume"`
+//		FS   string            `opt:"fs" default:"ext4" values:"ext4,xfs" help:"filesystem of the volume"`
+//		UID  *int              `opt:"uid" help:"owner of the volume"`
+//		Tags map[string]string `opt:"labels" help:"labels of the volume"`
+//	}
+//
+// The default tag sets the value of options not set, the values tag lists
+// the values allowed, and the help tag describes the option in Help.
+//
+// Fields can be of the types set by sdk.SetValue: strings, booleans,
+// numbers, durations, sizes, modes in octal, slices of strings separated by
+// commas or maps of strings set by comma separated key=value pairs. Pointer
+// fields are left nil when their option isn't set and has no default.
+type OptionsSchema struct {
+	typ     reflect.Type
+	options []option
+}
+
+// option is an option declared by a field.
+type option struct {
+	name     string
+	field    int
+	required bool
+	def      string
+	values   []string
+	help     string
+}
+
+// NewOptionsSchema returns the schema of the options declared by the
+// fields of the structure v, or points to.
+func NewOptionsSchema(v interface{}) (*OptionsSchema, error) {
+	typ := reflect.TypeOf(v)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("options must be declared by a structure, not %T", v)
+	}
+	s := &OptionsSchema{typ: typ}
+	seen := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, ok := f.Tag.Lookup("opt")
+		if !ok {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+		o := option{name: name, field: i, def: f.Tag.Get("default"), help: f.Tag.Get("help")}
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("field %s: empty option name", f.Name)
+		case seen[name]:
+			return nil, fmt.Errorf("field %s: option %s declared twice", f.Name, name)
+		case !f.IsExported():
+			return nil, fmt.Errorf("field %s: unexported field", f.Name)
+		case flags == "required":
+			o.required = true
+		case flags != "":
+			return nil, fmt.Errorf("field %s: unknown flag %q", f.Name, flags)
+		}
+		seen[name] = true
+		if values := f.Tag.Get("values"); values != "" {
+			o.values = strings.Split(values, ",")
+		}
+
+		// check the field, and the default with a value of the field
+		if err := sdk.CheckValueType(f.Type); err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		if o.def != "" {
+			if err := sdk.SetValue(reflect.New(f.Type).Elem(), o.def, o.values); err != nil {
+				return nil, fmt.Errorf("field %s: default: %v", f.Name, err)
+			}
+		}
+		s.options = append(s.options, o)
+	}
+	sort.Slice(s.options, func(i, j int) bool { return s.options[i].name < s.options[j].name })
+	return s, nil
+}
+
+// MustOptionsSchema is like NewOptionsSchema but panics if the options
+// can't be declared by v, to initialize global variables.
+func MustOptionsSchema(v interface{}) *OptionsSchema {
+	s, err := NewOptionsSchema(v)
+	if err != nil {
+		panic("volume: " + err.Error())
+	}
+	return s
+}
+
+// Names returns the sorted names of the options.
+func (s *OptionsSchema) Names() []string {
+	names := make([]string, len(s.options))
+	for i, o := range s.options {
+		names[i] = o.name
+	}
+	return names
+}
+
+// Split splits opts into the options of the schema, nil when none is set,
+// and the other ones, for drivers forwarding them to another driver.
+func (s *OptionsSchema) Split(opts map[string]string) (map[string]string, map[string]string) {
+	var own map[string]string
+	others := make(map[string]string, len(opts))
+	for k, v := range opts {
+		others[k] = v
+	}
+	for _, o := range s.options {
+		if v, ok := others[o.name]; ok {
+			if own == nil {
+				own = make(map[string]string)
+			}
+			own[o.name] = v
+			delete(others, o.name)
+		}
+	}
+	return own, others
+}
+
+// Decode sets the fields of the structure v points to from opts, such as
+// the options of a CreateRequest. Options not set get their default value,
+// fields of options without default are left unchanged. Unknown options,
+// missing required options and invalid values, including empty ones, are an
+// invalid argument error, listing every invalid option.
+func (s *OptionsSchema) Decode(opts map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Type().Elem() != s.typ {
+		return fmt.Errorf("options must be decoded to a *%s, not %T", s.typ, v)
+	}
+	rv = rv.Elem()
+
+	var errs []error
+	known := make(map[string]bool, len(s.options))
+	for _, o := range s.options {
+		known[o.name] = true
+		value, ok := opts[o.name]
+		switch {
+		case ok && value == "":
+			errs = append(errs, fmt.Errorf("invalid option %s=\"\": empty value", o.name))
+			continue
+		case !ok && o.required:
+			errs = append(errs, fmt.Errorf("missing required option %s", o.name))
+			continue
+		case !ok:
+			if value = o.def; value == "" {
+				continue
+			}
+		}
+		if err := sdk.SetValue(rv.Field(o.field), value, o.values); err != nil {
+			errs = append(errs, fmt.Errorf("invalid option %s=%q: %v", o.name, value, err))
+		}
+	}
+	var unknown []string
+	for k := range opts {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		errs = append(errs, fmt.Errorf("unknown option %s, supported options are %s", strings.Join(unknown, ", "), strings.Join(s.Names(), ", ")))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return sdk.InvalidArgument(err)
+	}
+	return nil
+}
+
+// Help returns a listing of the options, one per line, with their type,
+// description, default and allowed values, for the documentation of the
+// driver.
+func (s *OptionsSchema) Help() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, o := range s.options {
+		var notes []string
+		if o.required {
+			notes = append(notes, "required")
+		}
+		if o.def != "" {
+			notes = append(notes, "default "+o.def)
+		}
+		if len(o.values) > 0 {
+			notes = append(notes, "one of "+strings.Join(o.values, ", "))
+		}
+		help := o.help
+		if len(notes) > 0 {
+			help = strings.TrimSpace(help + " (" + strings.Join(notes, ", ") + ")")
+		}
+		fmt.Fprintf(w, "  %s=%s\t%s\n", o.name, sdk.ValueTypeName(s.typ.Field(o.field).Type), help)
+	}
+	w.Flush()
+	// options without description are padded by the writer
+	lines := strings.SplitAfter(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \n") + "\n"
+	}
+	return strings.Join(lines[:len(lines)-1], "")
+}
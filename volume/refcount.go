@@ -0,0 +1,117 @@
+package volume
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/store"
+)
+
+// mounts are the active mounts of a volume.
+type mounts struct {
+	// IDs are the IDs of the mount requests of the volume not unmounted.
+	IDs []string
+	// Mountpoint is the mountpoint returned by the first mount.
+	Mountpoint string
+	// MountID is the ID of the first mount, the one forwarded to the
+	// driver, which is unmounted with it.
+	MountID string `json:",omitempty"`
+}
+
+func (m *mounts) index(id string) int {
+	for i, v := range m.IDs {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// refCountDriver only forwards the first mount and the last unmount of a
+// volume.
+type refCountDriver struct {
+	Driver
+
+	// mu serializes the counting of mounts, so that a volume is mounted
+	// by driver once whatever the concurrent mounts.
+	mu      sync.Mutex
+	volumes *store.Store[mounts]
+}
+
+// NewRefCountDriver returns a Driver counting the mounts of the volumes of
+// driver by mount ID. Only the first mount of a volume is forwarded to
+// driver, and only the unmount of its last mount. Volumes can't be removed
+// while mounted. The mounts are saved to a store at path, to be counted
+// across restarts of the plugin.
+func NewRefCountDriver(driver Driver, path string) (Driver, error) {
+	volumes, err := store.Open[mounts](path, store.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &refCountDriver{Driver: driver, volumes: volumes}, nil
+}
+
+func (d *refCountDriver) Mount(req *MountRequest) (*MountResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, mounted := d.volumes.Get(req.Name)
+	if m.index(req.ID) >= 0 {
+		return &MountResponse{Mountpoint: m.Mountpoint}, nil
+	}
+	if !mounted {
+		res, err := d.Driver.Mount(req)
+		if err != nil {
+			return nil, err
+		}
+		if res != nil {
+			m.Mountpoint = res.Mountpoint
+		}
+		m.MountID = req.ID
+	}
+
+	// appending copies the IDs rather than extending the array of the
+	// stored value, which Put replaces
+	m.IDs = append(m.IDs[:len(m.IDs):len(m.IDs)], req.ID)
+	if err := d.volumes.Put(req.Name, m); err != nil {
+		if !mounted {
+			d.Driver.Unmount(&UnmountRequest{Name: req.Name, ID: req.ID})
+		}
+		return nil, err
+	}
+	return &MountResponse{Mountpoint: m.Mountpoint}, nil
+}
+
+func (d *refCountDriver) Unmount(req *UnmountRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, _ := d.volumes.Get(req.Name)
+	i := m.index(req.ID)
+	if i < 0 {
+		return sdk.NotFound(fmt.Errorf("volume %s is not mounted by %s", req.Name, req.ID))
+	}
+	if len(m.IDs) == 1 {
+		// the mounts saved by older versions have no MountID
+		id := m.MountID
+		if id == "" {
+			id = req.ID
+		}
+		if err := d.Driver.Unmount(&UnmountRequest{Name: req.Name, ID: id}); err != nil {
+			return err
+		}
+		return d.volumes.Delete(req.Name)
+	}
+	// the remaining IDs are copied, removing in place would change the
+	// stored value if Put failed
+	m.IDs = append(append([]string(nil), m.IDs[:i]...), m.IDs[i+1:]...)
+	return d.volumes.Put(req.Name, m)
+}
+
+func (d *refCountDriver) Remove(req *RemoveRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if m, ok := d.volumes.Get(req.Name); ok {
+		return sdk.Conflict(fmt.Errorf("volume %s is in use by %d mounts", req.Name, len(m.IDs)))
+	}
+	return d.Driver.Remove(req)
+}
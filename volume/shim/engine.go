@@ -0,0 +1,167 @@
+package shim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/volume"
+	"github.com/docker/go-plugins-helpers/sdk"
+	volumeplugin "github.com/docker/go-plugins-helpers/volume"
+)
+
+// engineDriver is a Docker Engine volume driver calling a plugin driver.
+type engineDriver struct {
+	name string
+	d    volumeplugin.Driver
+}
+
+// NewVolumeDriver creates a Docker Engine volume driver named name from a
+// plugin driver, the reverse of NewHandlerFromVolumeDriver. The driver can
+// be implemented in process, or be a volumeplugin.Client to a running
+// plugin, to use the volumes of plugins wherever Docker Engine volume
+// drivers are expected, such as in test tools.
+//
+// Errors of the plugin driver are reported as the matching errdefs errors.
+func NewVolumeDriver(name string, d volumeplugin.Driver) volume.Driver {
+	return &engineDriver{name: name, d: d}
+}
+
+func (d *engineDriver) Name() string {
+	return d.name
+}
+
+// Create creates the volume, and returns it as reported by the plugin driver
+// right after, with its creation time and status.
+func (d *engineDriver) Create(name string, opts map[string]string) (volume.Volume, error) {
+	if err := d.d.Create(&volumeplugin.CreateRequest{Name: name, Options: opts}); err != nil {
+		return nil, engineError(err)
+	}
+	return d.Get(name)
+}
+
+func (d *engineDriver) Remove(v volume.Volume) error {
+	return engineError(d.d.Remove(&volumeplugin.RemoveRequest{Name: v.Name()}))
+}
+
+func (d *engineDriver) List() ([]volume.Volume, error) {
+	res, err := d.d.List()
+	if err != nil {
+		return nil, engineError(err)
+	}
+	var vols []volume.Volume
+	if res == nil {
+		return vols, nil
+	}
+	for _, v := range res.Volumes {
+		if v != nil {
+			vols = append(vols, d.volume(v))
+		}
+	}
+	return vols, nil
+}
+
+func (d *engineDriver) Get(name string) (volume.Volume, error) {
+	res, err := d.d.Get(&volumeplugin.GetRequest{Name: name})
+	if err != nil {
+		return nil, engineError(err)
+	}
+	if res == nil || res.Volume == nil {
+		return nil, errdefs.NotFound(fmt.Errorf("no such volume: %s", name))
+	}
+	return d.volume(res.Volume), nil
+}
+
+// Scope returns the scope reported by the capabilities of the plugin
+// driver, local by default as for the daemon.
+func (d *engineDriver) Scope() string {
+	if res := d.d.Capabilities(); res != nil && res.Capabilities.Scope != "" {
+		return res.Capabilities.Scope
+	}
+	return volume.LocalScope
+}
+
+func (d *engineDriver) volume(v *volumeplugin.Volume) *engineVolume {
+	// volumes without creation time are reported as created at zero
+	createdAt, _ := time.Parse(time.RFC3339, v.CreatedAt)
+	return &engineVolume{d: d, name: v.Name, mountpoint: v.Mountpoint, createdAt: createdAt, status: v.Status}
+}
+
+// engineVolume is a Docker Engine volume of a plugin driver.
+type engineVolume struct {
+	d         *engineDriver
+	name      string
+	createdAt time.Time
+	status    map[string]interface{}
+
+	// mountpoint is the mountpoint reported by the plugin driver, asked
+	// for by Path when unknown.
+	mu         sync.Mutex
+	mountpoint string
+}
+
+func (v *engineVolume) Name() string {
+	return v.name
+}
+
+func (v *engineVolume) DriverName() string {
+	return v.d.name
+}
+
+// Path returns the mountpoint of the volume, empty when the plugin driver
+// fails to report it.
+func (v *engineVolume) Path() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.mountpoint == "" {
+		res, err := v.d.d.Path(&volumeplugin.PathRequest{Name: v.name})
+		if err == nil && res != nil {
+			v.mountpoint = res.Mountpoint
+		}
+	}
+	return v.mountpoint
+}
+
+func (v *engineVolume) Mount(id string) (string, error) {
+	res, err := v.d.d.Mount(&volumeplugin.MountRequest{Name: v.name, ID: id})
+	if err != nil {
+		return "", engineError(err)
+	}
+	var mountpoint string
+	if res != nil {
+		mountpoint = res.Mountpoint
+	}
+	v.mu.Lock()
+	v.mountpoint = mountpoint
+	v.mu.Unlock()
+	return mountpoint, nil
+}
+
+func (v *engineVolume) Unmount(id string) error {
+	if err := v.d.d.Unmount(&volumeplugin.UnmountRequest{Name: v.name, ID: id}); err != nil {
+		return engineError(err)
+	}
+	// the mountpoint may change with the next mount
+	v.mu.Lock()
+	v.mountpoint = ""
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *engineVolume) CreatedAt() (time.Time, error) {
+	return v.createdAt, nil
+}
+
+func (v *engineVolume) Status() map[string]interface{} {
+	return v.status
+}
+
+// engineError returns err as the matching errdefs error. Plugin errors
+// match them already, but for invalid arguments.
+func engineError(err error) error {
+	if err != nil && sdk.IsInvalidArgument(err) {
+		return errdefs.InvalidParameter(err)
+	}
+	return err
+}
@@ -1,7 +1,12 @@
 package shim
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/volume"
+	"github.com/docker/go-plugins-helpers/sdk"
 	volumeplugin "github.com/docker/go-plugins-helpers/volume"
 )
 
@@ -14,96 +19,136 @@ type shimDriver struct {
 // to Docker Engine and it would create a plugin from it that maps plugin API calls
 // directly to any volume driver that satisfies the volume.Driver interface from
 // Docker Engine.
+//
+// Errors of the driver are reported with the status code of their errdefs
+// type, see sdk.StatusCode.
 func NewHandlerFromVolumeDriver(d volume.Driver) *volumeplugin.Handler {
 	return volumeplugin.NewHandler(&shimDriver{d})
 }
 
 func (d *shimDriver) Create(req *volumeplugin.CreateRequest) error {
-	_, err := d.d.Create(req.Name, req.Options)
-	return err
+	v, err := d.d.Create(req.Name, req.Options)
+	if err != nil {
+		return pluginError(err)
+	}
+	if v == nil {
+		return fmt.Errorf("driver %s created no volume %s", d.d.Name(), req.Name)
+	}
+	return nil
 }
 
 func (d *shimDriver) List() (*volumeplugin.ListResponse, error) {
-	var res *volumeplugin.ListResponse
 	ls, err := d.d.List()
 	if err != nil {
-		return &volumeplugin.ListResponse{}, err
+		return nil, pluginError(err)
 	}
-	vols := make([]*volumeplugin.Volume, len(ls))
-
-	for i, v := range ls {
-		vol := &volumeplugin.Volume{
-			Name:       v.Name(),
-			Mountpoint: v.Path(),
+	res := &volumeplugin.ListResponse{Volumes: make([]*volumeplugin.Volume, 0, len(ls))}
+	for _, v := range ls {
+		if v != nil {
+			res.Volumes = append(res.Volumes, &volumeplugin.Volume{Name: v.Name(), Mountpoint: v.Path()})
 		}
-		vols[i] = vol
 	}
-	res.Volumes = vols
 	return res, nil
 }
 
 func (d *shimDriver) Get(req *volumeplugin.GetRequest) (*volumeplugin.GetResponse, error) {
-	var res *volumeplugin.GetResponse
-	v, err := d.d.Get(req.Name)
+	v, err := d.get(req.Name)
 	if err != nil {
-		return &volumeplugin.GetResponse{}, err
+		return nil, err
 	}
-	res.Volume = &volumeplugin.Volume{
+	vol := &volumeplugin.Volume{
 		Name:       v.Name(),
 		Mountpoint: v.Path(),
 		Status:     v.Status(),
 	}
-	return &volumeplugin.GetResponse{}, nil
+	if createdAt, err := v.CreatedAt(); err == nil && !createdAt.IsZero() {
+		vol.CreatedAt = createdAt.Format(time.RFC3339)
+	}
+	// the labels and options of detailed volumes are only reported by
+	// the daemon, they are added to their status
+	if dv, ok := v.(volume.DetailedVolume); ok && (len(dv.Labels()) > 0 || len(dv.Options()) > 0) {
+		status := make(map[string]interface{}, len(vol.Status)+2)
+		for k, v := range vol.Status {
+			status[k] = v
+		}
+		if _, ok := status["Labels"]; !ok && len(dv.Labels()) > 0 {
+			status["Labels"] = dv.Labels()
+		}
+		if _, ok := status["Options"]; !ok && len(dv.Options()) > 0 {
+			status["Options"] = dv.Options()
+		}
+		vol.Status = status
+	}
+	return &volumeplugin.GetResponse{Volume: vol}, nil
 }
 
 func (d *shimDriver) Remove(req *volumeplugin.RemoveRequest) error {
-	v, err := d.d.Get(req.Name)
+	v, err := d.get(req.Name)
 	if err != nil {
 		return err
 	}
-	if err := d.d.Remove(v); err != nil {
-		return err
-	}
-	return nil
+	return pluginError(d.d.Remove(v))
 }
 
 func (d *shimDriver) Path(req *volumeplugin.PathRequest) (*volumeplugin.PathResponse, error) {
-	var res *volumeplugin.PathResponse
-	v, err := d.d.Get(req.Name)
+	v, err := d.get(req.Name)
 	if err != nil {
-		return &volumeplugin.PathResponse{}, err
+		return nil, err
 	}
-	res.Mountpoint = v.Path()
-	return res, nil
+	return &volumeplugin.PathResponse{Mountpoint: v.Path()}, nil
 }
 
 func (d *shimDriver) Mount(req *volumeplugin.MountRequest) (*volumeplugin.MountResponse, error) {
-	var res *volumeplugin.MountResponse
-	v, err := d.d.Get(req.Name)
+	v, err := d.get(req.Name)
 	if err != nil {
-		return &volumeplugin.MountResponse{}, err
+		return nil, err
 	}
 	pth, err := v.Mount(req.ID)
 	if err != nil {
-		return &volumeplugin.MountResponse{}, err
+		return nil, pluginError(err)
 	}
-	res.Mountpoint = pth
-	return res, nil
+	return &volumeplugin.MountResponse{Mountpoint: pth}, nil
 }
 
 func (d *shimDriver) Unmount(req *volumeplugin.UnmountRequest) error {
-	v, err := d.d.Get(req.Name)
+	v, err := d.get(req.Name)
 	if err != nil {
 		return err
 	}
-	if err := v.Unmount(req.ID); err != nil {
-		return err
-	}
-	return nil
+	return pluginError(v.Unmount(req.ID))
 }
 
 func (d *shimDriver) Capabilities() *volumeplugin.CapabilitiesResponse {
-	var res *volumeplugin.CapabilitiesResponse
-	res.Capabilities = volumeplugin.Capability{Scope: d.d.Scope()}
-	return res
+	return &volumeplugin.CapabilitiesResponse{Capabilities: volumeplugin.Capability{Scope: d.d.Scope()}}
+}
+
+// get returns the volume name of the driver, or a not found error when the
+// driver returns no volume.
+func (d *shimDriver) get(name string) (volume.Volume, error) {
+	v, err := d.d.Get(name)
+	if err != nil {
+		return nil, pluginError(err)
+	}
+	if v == nil {
+		return nil, sdk.NotFound(fmt.Errorf("no such volume: %s", name))
+	}
+	return v, nil
+}
+
+// pluginError returns err as the plugin error matching its errdefs type, for
+// the handler to reply with its status code.
+func pluginError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errdefs.IsNotFound(err):
+		return sdk.NotFound(err)
+	case errdefs.IsConflict(err):
+		return sdk.Conflict(err)
+	case errdefs.IsInvalidParameter(err):
+		return sdk.InvalidArgument(err)
+	case errdefs.IsUnavailable(err):
+		return sdk.Unavailable(err)
+	}
+	return err
 }
@@ -1,59 +1,318 @@
 package shim
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/volume"
 	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-plugins-helpers/sdk"
 	volumeplugin "github.com/docker/go-plugins-helpers/volume"
+	"github.com/docker/go-plugins-helpers/volume/local"
 )
 
-type testVolumeDriver struct{}
+var testCreatedAt = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
 
-func (testVolumeDriver) Name() string                                            { return "" }
-func (testVolumeDriver) Create(string, map[string]string) (volume.Volume, error) { return nil, nil }
-func (testVolumeDriver) Remove(volume.Volume) error                              { return nil }
-func (testVolumeDriver) List() ([]volume.Volume, error)                          { return nil, nil }
-func (testVolumeDriver) Get(name string) (volume.Volume, error)                  { return nil, nil }
-func (testVolumeDriver) Scope() string                                           { return "local" }
+// testVolumeDriver is a Docker Engine volume driver keeping its volumes in
+// memory, with the errdefs errors of the drivers of the daemon.
+type testVolumeDriver struct {
+	mu      sync.Mutex
+	volumes map[string]*testVolume
+}
+
+func (*testVolumeDriver) Name() string  { return "fake" }
+func (*testVolumeDriver) Scope() string { return volume.GlobalScope }
+
+func (d *testVolumeDriver) Create(name string, opts map[string]string) (volume.Volume, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := opts["invalid"]; ok {
+		return nil, errdefs.InvalidParameter(errors.New("invalid option"))
+	}
+	if _, ok := opts["unavailable"]; ok {
+		return nil, errdefs.Unavailable(errors.New("backend unavailable"))
+	}
+	if v, ok := d.volumes[name]; ok {
+		return v, nil
+	}
+	v := &testVolume{name: name, opts: opts, mounts: make(map[string]bool)}
+	d.volumes[name] = v
+	return v, nil
+}
+
+func (d *testVolumeDriver) Remove(v volume.Volume) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(v.(*testVolume).mounts) > 0 {
+		return errdefs.Conflict(fmt.Errorf("volume %s is in use", v.Name()))
+	}
+	delete(d.volumes, v.Name())
+	return nil
+}
+
+func (d *testVolumeDriver) List() ([]volume.Volume, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var vols []volume.Volume
+	for _, v := range d.volumes {
+		vols = append(vols, v)
+	}
+	sort.Slice(vols, func(i, j int) bool { return vols[i].Name() < vols[j].Name() })
+	return vols, nil
+}
+
+func (d *testVolumeDriver) Get(name string) (volume.Volume, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if v, ok := d.volumes[name]; ok {
+		return v, nil
+	}
+	return nil, errdefs.NotFound(fmt.Errorf("volume %s not found", name))
+}
+
+type testVolume struct {
+	name   string
+	opts   map[string]string
+	mounts map[string]bool
+}
+
+var _ volume.DetailedVolume = (*testVolume)(nil)
+
+func (v *testVolume) Name() string                  { return v.name }
+func (v *testVolume) DriverName() string            { return "fake" }
+func (v *testVolume) Path() string                  { return "/volumes/" + v.name }
+func (v *testVolume) CreatedAt() (time.Time, error) { return testCreatedAt, nil }
+func (v *testVolume) Status() map[string]interface{} {
+	return map[string]interface{}{"Mounts": len(v.mounts)}
+}
+func (v *testVolume) Labels() map[string]string  { return map[string]string{"env": "test"} }
+func (v *testVolume) Options() map[string]string { return v.opts }
+func (v *testVolume) Scope() string              { return volume.GlobalScope }
+
+// Mount and Unmount are only called sequentially by the test
+func (v *testVolume) Mount(id string) (string, error) {
+	if id == "" {
+		return "", errdefs.InvalidParameter(errors.New("missing mount ID"))
+	}
+	v.mounts[id] = true
+	return v.Path(), nil
+}
+
+func (v *testVolume) Unmount(id string) error {
+	if !v.mounts[id] {
+		return errdefs.NotFound(fmt.Errorf("volume %s is not mounted by %s", v.name, id))
+	}
+	delete(v.mounts, id)
+	return nil
+}
 
 func TestVolumeDriver(t *testing.T) {
-	h := NewHandlerFromVolumeDriver(testVolumeDriver{})
+	h := NewHandlerFromVolumeDriver(&testVolumeDriver{volumes: make(map[string]*testVolume)})
 	l := sockets.NewInmemSocket("test", 0)
 	go h.Serve(l)
 	defer l.Close()
+	c := volumeplugin.NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
 
-	client := &http.Client{Transport: &http.Transport{
-		Dial: l.Dial,
-	}}
+	// Create
+	if err := c.Create(&volumeplugin.CreateRequest{Name: "foo", Options: map[string]string{"size": "1g"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Create(&volumeplugin.CreateRequest{Name: "bar", Options: map[string]string{"invalid": ""}}); !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+	if err := c.Create(&volumeplugin.CreateRequest{Name: "bar", Options: map[string]string{"unavailable": ""}}); !sdk.IsUnavailable(err) {
+		t.Fatalf("expected unavailable, got %v", err)
+	}
+
+	// List
+	list, err := c.List()
+	if err != nil || len(list.Volumes) != 1 || list.Volumes[0].Name != "foo" || list.Volumes[0].Mountpoint != "/volumes/foo" {
+		t.Fatalf("unexpected list %+v, %v", list, err)
+	}
 
-	resp, err := pluginRequest(client, "/VolumeDriver.Create", &volumeplugin.CreateRequest{Name: "foo"})
+	// Get
+	get, err := c.Get(&volumeplugin.GetRequest{Name: "foo"})
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
+	}
+	v := get.Volume
+	if v.Name != "foo" || v.Mountpoint != "/volumes/foo" || v.CreatedAt != testCreatedAt.Format(time.RFC3339) {
+		t.Fatalf("unexpected volume %+v", v)
+	}
+	if fmt.Sprint(v.Status) != "map[Labels:map[env:test] Mounts:0 Options:map[size:1g]]" {
+		t.Fatalf("unexpected status %v", v.Status)
+	}
+	if _, err := c.Get(&volumeplugin.GetRequest{Name: "bar"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
 	}
 
-	if resp.Err != "" {
-		t.Fatalf("error while creating volume: %v", err)
+	// Path
+	if p, err := c.Path(&volumeplugin.PathRequest{Name: "foo"}); err != nil || p.Mountpoint != "/volumes/foo" {
+		t.Fatalf("unexpected path %+v, %v", p, err)
+	}
+	if _, err := c.Path(&volumeplugin.PathRequest{Name: "bar"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// Mount
+	for _, id := range []string{"a", "b"} {
+		if m, err := c.Mount(&volumeplugin.MountRequest{Name: "foo", ID: id}); err != nil || m.Mountpoint != "/volumes/foo" {
+			t.Fatalf("unexpected mount %+v, %v", m, err)
+		}
+	}
+	if _, err := c.Mount(&volumeplugin.MountRequest{Name: "foo"}); !sdk.IsInvalidArgument(err) {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+	if _, err := c.Mount(&volumeplugin.MountRequest{Name: "bar", ID: "a"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if get, err := c.Get(&volumeplugin.GetRequest{Name: "foo"}); err != nil || get.Volume.Status["Mounts"] != 2.0 {
+		t.Fatalf("expected the mounts by ID in the status, got %+v, %v", get, err)
+	}
+
+	// Remove of a mounted volume
+	if err := c.Remove(&volumeplugin.RemoveRequest{Name: "foo"}); !sdk.IsConflict(err) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	// Unmount
+	if err := c.Unmount(&volumeplugin.UnmountRequest{Name: "foo", ID: "c"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := c.Unmount(&volumeplugin.UnmountRequest{Name: "foo", ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Remove
+	if err := c.Remove(&volumeplugin.RemoveRequest{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(&volumeplugin.RemoveRequest{Name: "foo"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if list, err := c.List(); err != nil || len(list.Volumes) != 0 {
+		t.Fatalf("expected no volumes, got %+v, %v", list, err)
+	}
+
+	// Capabilities
+	if caps := c.Capabilities(); caps.Capabilities.Scope != volume.GlobalScope {
+		t.Fatalf("unexpected capabilities %+v", caps)
 	}
 }
 
-func pluginRequest(client *http.Client, method string, req *volumeplugin.CreateRequest) (*volumeplugin.ErrorResponse, error) {
-	b, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
+// nilVolumeDriver is a Docker Engine volume driver returning no volumes
+// and no errors.
+type nilVolumeDriver struct{}
+
+func (nilVolumeDriver) Name() string                                            { return "nil" }
+func (nilVolumeDriver) Create(string, map[string]string) (volume.Volume, error) { return nil, nil }
+func (nilVolumeDriver) Remove(volume.Volume) error                              { return nil }
+func (nilVolumeDriver) List() ([]volume.Volume, error)                          { return []volume.Volume{nil}, nil }
+func (nilVolumeDriver) Get(name string) (volume.Volume, error)                  { return nil, nil }
+func (nilVolumeDriver) Scope() string                                           { return volume.LocalScope }
+
+func TestVolumeDriverNilVolumes(t *testing.T) {
+	d := &shimDriver{nilVolumeDriver{}}
+	if err := d.Create(&volumeplugin.CreateRequest{Name: "foo"}); err == nil {
+		t.Fatal("expected an error creating no volume")
 	}
-	resp, err := client.Post("http://localhost"+method, "application/json", bytes.NewReader(b))
-	if err != nil {
-		return nil, err
+	if list, err := d.List(); err != nil || len(list.Volumes) != 0 {
+		t.Fatalf("expected no volumes, got %+v, %v", list, err)
+	}
+	if _, err := d.Get(&volumeplugin.GetRequest{Name: "foo"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
 	}
-	var vResp volumeplugin.ErrorResponse
-	err = json.NewDecoder(resp.Body).Decode(&vResp)
+	if _, err := d.Mount(&volumeplugin.MountRequest{Name: "foo", ID: "a"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := d.Remove(&volumeplugin.RemoveRequest{Name: "foo"}); !sdk.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestNewVolumeDriver(t *testing.T) {
+	driver, err := local.NewDriver(t.TempDir())
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
 	}
+	l := sockets.NewInmemSocket("test", 0)
+	go volumeplugin.NewHandler(driver).Serve(l)
+	defer l.Close()
+	client := volumeplugin.NewClient(sdk.NewClientWithTransport(&http.Transport{Dial: l.Dial}))
+
+	for name, d := range map[string]volume.Driver{
+		"local":  NewVolumeDriver("test", driver),
+		"client": NewVolumeDriver("test", client),
+	} {
+		if d.Name() != "test" || d.Scope() != volume.LocalScope {
+			t.Fatalf("%s: unexpected driver %s scoped %s", name, d.Name(), d.Scope())
+		}
+		v, err := d.Create(name, map[string]string{"labels": "env=test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Name() != name || v.DriverName() != "test" || v.Path() == "" {
+			t.Fatalf("%s: unexpected volume %s of %s at %s", name, v.Name(), v.DriverName(), v.Path())
+		}
+		if createdAt, err := v.CreatedAt(); err != nil || createdAt.IsZero() {
+			t.Fatalf("%s: expected the creation time of the volume created, got %v, %v", name, createdAt, err)
+		}
+		if labels := fmt.Sprint(v.Status()["Labels"]); labels != "map[env:test]" {
+			t.Fatalf("%s: unexpected status of the volume created %v", name, v.Status())
+		}
+		if _, err := d.Create(name, map[string]string{"color": "red"}); !errdefs.IsInvalidParameter(err) {
+			t.Fatalf("%s: expected an invalid parameter creating a volume with an unknown option, got %v", name, err)
+		}
+
+		v, err = d.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if createdAt, err := v.CreatedAt(); err != nil || createdAt.IsZero() {
+			t.Fatalf("%s: expected the creation time, got %v, %v", name, createdAt, err)
+		}
+		// the status of the client is decoded from JSON, its labels are a
+		// map[string]interface{}
+		if labels := fmt.Sprint(v.Status()["Labels"]); labels != "map[env:test]" {
+			t.Fatalf("%s: unexpected status %v", name, v.Status())
+		}
 
-	return &vResp, nil
+		mountpoint, err := v.Mount("a")
+		if err != nil || mountpoint != v.Path() {
+			t.Fatalf("%s: unexpected mountpoint %s, %v", name, mountpoint, err)
+		}
+		if err := d.Remove(v); !errdefs.IsConflict(err) {
+			t.Fatalf("%s: expected a conflict removing a mounted volume, got %v", name, err)
+		}
+		if err := v.Unmount("b"); !errdefs.IsNotFound(err) {
+			t.Fatalf("%s: expected not found unmounting an unknown mount, got %v", name, err)
+		}
+		if err := v.Unmount("a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Remove(v); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Get(name); !errdefs.IsNotFound(err) {
+			t.Fatalf("%s: expected not found getting a removed volume, got %v", name, err)
+		}
+	}
+
+	// both drivers share the volumes of the local driver
+	d := NewVolumeDriver("test", client)
+	if _, err := NewVolumeDriver("test", driver).Create("shared", nil); err != nil {
+		t.Fatal(err)
+	}
+	vols, err := d.List()
+	if err != nil || len(vols) != 1 || vols[0].Name() != "shared" || vols[0].Path() == "" {
+		t.Fatalf("unexpected volumes %v, %v", vols, err)
+	}
 }
@@ -0,0 +1,202 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/store"
+)
+
+// Snapshotter snapshots and clones the volumes of a driver.
+type Snapshotter interface {
+	// Snapshot takes the snapshot name of the volume.
+	Snapshot(volume, name string) error
+	// RemoveSnapshot removes the snapshot name of the volume.
+	RemoveSnapshot(volume, name string) error
+	// Clone creates the volume of req with the content of the snapshot of
+	// the volume source, or of the volume source itself when snapshot is
+	// empty. The options of req are the ones of the volume created.
+	Clone(req *CreateRequest, source, snapshot string) error
+}
+
+// CloneOptions are the options of the volumes of the drivers returned by
+// NewSnapshotDriver, taken out of the options of the volumes they create.
+type CloneOptions struct {
+	From     string `opt:"from" help:"volume cloned by the volume"`
+	Snapshot string `opt:"snapshot" help:"snapshot of the volume cloned, taken if missing, the current content by default"`
+}
+
+// CloneOptionsSchema is the schema of CloneOptions, to list them in the
+// documentation of drivers.
+var CloneOptionsSchema = MustOptionsSchema(CloneOptions{})
+
+// snapshotVolume is the snapshots of a volume, and the volume it clones.
+type snapshotVolume struct {
+	// Snapshots are the sorted names of the snapshots of the volume.
+	Snapshots []string `json:",omitempty"`
+	// Source is the volume cloned by the volume, from its snapshot
+	// Snapshot when set.
+	Source   string `json:",omitempty"`
+	Snapshot string `json:",omitempty"`
+}
+
+// snapshotDriver clones volumes with a Snapshotter.
+type snapshotDriver struct {
+	Driver
+	snapshotter Snapshotter
+
+	// mu serializes the snapshots and clones with the changes to the
+	// store.
+	mu      sync.Mutex
+	volumes *store.Store[snapshotVolume]
+}
+
+// NewSnapshotDriver returns a Driver cloning the volumes of driver with
+// snapshotter, for volumes created with the from option, see CloneOptions.
+// Volumes created with from=<volume> clone the content of the volume, and
+// volumes also created with snapshot=<name> clone the snapshot of the
+// volume with this name, taken by the first volume cloning it.
+//
+// The status of the volumes lists their snapshots, their clones and the
+// volume they clone. Volumes can't be removed while cloned by other
+// volumes, and their snapshots are removed with them. The snapshots and
+// clones are saved to a store at path.
+func NewSnapshotDriver(driver Driver, snapshotter Snapshotter, path string) (Driver, error) {
+	volumes, err := store.Open[snapshotVolume](path, store.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotDriver{Driver: driver, snapshotter: snapshotter, volumes: volumes}, nil
+}
+
+func (d *snapshotDriver) Create(req *CreateRequest) error {
+	opts, forward := CloneOptionsSchema.Split(req.Options)
+	if opts == nil {
+		return d.Driver.Create(req)
+	}
+	var clone CloneOptions
+	if err := CloneOptionsSchema.Decode(opts, &clone); err != nil {
+		return err
+	}
+	switch {
+	case clone.From == "":
+		return sdk.InvalidArgument(errors.New("snapshot option without from option"))
+	case clone.From == req.Name:
+		return sdk.InvalidArgument(fmt.Errorf("volume %s can't clone itself", req.Name))
+	case clone.Snapshot != "" && !validSubdir(clone.Snapshot):
+		return sdk.InvalidArgument(fmt.Errorf("invalid snapshot name %q", clone.Snapshot))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if v, ok := d.volumes.Get(req.Name); ok && v.Source != "" {
+		return nil
+	}
+	if _, err := d.Driver.Get(&GetRequest{Name: req.Name}); err == nil {
+		return sdk.Conflict(fmt.Errorf("volume %s already exists", req.Name))
+	}
+	if _, err := d.Driver.Get(&GetRequest{Name: clone.From}); err != nil {
+		return err
+	}
+	source, _ := d.volumes.Get(clone.From)
+	if clone.Snapshot != "" && !contains(source.Snapshots, clone.Snapshot) {
+		if err := d.snapshotter.Snapshot(clone.From, clone.Snapshot); err != nil {
+			return err
+		}
+		// Get reads the stored snapshots without mu, appending copies them
+		source.Snapshots = append(source.Snapshots[:len(source.Snapshots):len(source.Snapshots)], clone.Snapshot)
+		sort.Strings(source.Snapshots)
+		if err := d.volumes.Put(clone.From, source); err != nil {
+			d.snapshotter.RemoveSnapshot(clone.From, clone.Snapshot)
+			return err
+		}
+	}
+
+	fwd := *req
+	fwd.Options = forward
+	if err := d.snapshotter.Clone(&fwd, clone.From, clone.Snapshot); err != nil {
+		return err
+	}
+	if err := d.volumes.Put(req.Name, snapshotVolume{Source: clone.From, Snapshot: clone.Snapshot}); err != nil {
+		d.Driver.Remove(&RemoveRequest{Name: req.Name})
+		return err
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// clones returns the sorted clones of the volume name.
+func (d *snapshotDriver) clones(name string) []string {
+	var clones []string
+	for _, k := range d.volumes.Keys() {
+		if v, _ := d.volumes.Get(k); v.Source == name {
+			clones = append(clones, k)
+		}
+	}
+	return clones
+}
+
+func (d *snapshotDriver) Remove(req *RemoveRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if clones := d.clones(req.Name); len(clones) > 0 {
+		return sdk.Conflict(fmt.Errorf("volume %s is cloned by %s", req.Name, strings.Join(clones, ", ")))
+	}
+	if err := d.Driver.Remove(req); err != nil {
+		return err
+	}
+	v, ok := d.volumes.Get(req.Name)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, snapshot := range v.Snapshots {
+		if err := d.snapshotter.RemoveSnapshot(req.Name, snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("removing snapshot %s of %s: %v", snapshot, req.Name, err))
+		}
+	}
+	if err := d.volumes.Delete(req.Name); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (d *snapshotDriver) Get(req *GetRequest) (*GetResponse, error) {
+	res, err := d.Driver.Get(req)
+	if err != nil || res == nil || res.Volume == nil {
+		return res, err
+	}
+	v, _ := d.volumes.Get(req.Name)
+	clones := d.clones(req.Name)
+	if len(v.Snapshots) == 0 && len(clones) == 0 && v.Source == "" {
+		return res, nil
+	}
+
+	status := make(map[string]interface{}, 3)
+	if len(v.Snapshots) > 0 {
+		status["Snapshots"] = v.Snapshots
+	}
+	if len(clones) > 0 {
+		status["Clones"] = clones
+	}
+	if v.Source != "" {
+		from := v.Source
+		if v.Snapshot != "" {
+			from += "@" + v.Snapshot
+		}
+		status["ClonedFrom"] = from
+	}
+	return &GetResponse{Volume: withStatus(res.Volume, status)}, nil
+}
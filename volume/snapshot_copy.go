@@ -0,0 +1,191 @@
+package volume
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// copySnapshotter snapshots and clones volumes by copying their files.
+type copySnapshotter struct {
+	driver Driver
+	dir    string
+}
+
+// NewCopySnapshotter returns a Snapshotter for drivers storing volumes in
+// directories, such as volume/local, copying the files of the volumes of
+// driver from the path it returns. The snapshots are copied to dir, and
+// the clones are created by driver before copying the files of the volume
+// or snapshot cloned.
+//
+// The files are copied with their mode, owner and modification time, but
+// files linked more than once are copied at every link, and files other
+// than directories, regular files and symbolic links are an error.
+func NewCopySnapshotter(driver Driver, dir string) Snapshotter {
+	return &copySnapshotter{driver: driver, dir: dir}
+}
+
+func (s *copySnapshotter) path(volume string) (string, error) {
+	return volumePath(s.driver, volume)
+}
+
+// volumePath returns the path of the volume name of driver.
+func volumePath(driver Driver, name string) (string, error) {
+	res, err := driver.Path(&PathRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	if res == nil || res.Mountpoint == "" {
+		return "", fmt.Errorf("volume %s has no path", name)
+	}
+	return res.Mountpoint, nil
+}
+
+func (s *copySnapshotter) Snapshot(volume, name string) error {
+	src, err := s.path(volume)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(s.dir, volume, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	// snapshots are copied aside, to never be seen partially copied
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+name+".")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := copyTree(tmp, src); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (s *copySnapshotter) RemoveSnapshot(volume, name string) error {
+	if err := os.RemoveAll(filepath.Join(s.dir, volume, name)); err != nil {
+		return err
+	}
+	// the directory of the volume is only removed once empty
+	os.Remove(filepath.Join(s.dir, volume))
+	return nil
+}
+
+func (s *copySnapshotter) Clone(req *CreateRequest, source, snapshot string) error {
+	src := filepath.Join(s.dir, source, snapshot)
+	if snapshot == "" {
+		var err error
+		if src, err = s.path(source); err != nil {
+			return err
+		}
+	}
+	if err := s.driver.Create(req); err != nil {
+		return err
+	}
+	dst, err := s.path(req.Name)
+	if err == nil {
+		err = copyTree(dst, src)
+	}
+	if err != nil {
+		s.driver.Remove(&RemoveRequest{Name: req.Name})
+		return err
+	}
+	return nil
+}
+
+// copyTree copies the files of the directory src to the directory dst,
+// and the mode, owner and modification time of src to dst.
+func copyTree(dst, src string) error {
+	type dir struct {
+		path string
+		fi   fs.FileInfo
+	}
+	var dirs []dir
+	err := filepath.WalkDir(src, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		fi, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fi.IsDir():
+			if err := os.Mkdir(target, 0700); err != nil && !(rel == "." && os.IsExist(err)) {
+				return err
+			}
+			dirs = append(dirs, dir{target, fi})
+			return chownAs(target, fi)
+		case fi.Mode().IsRegular():
+			if err := copyFile(target, path); err != nil {
+				return err
+			}
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			// links are copied as is, not followed
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			return chownAs(target, fi)
+		default:
+			return fmt.Errorf("%s: unsupported file type %s", path, fi.Mode().Type())
+		}
+		if err := chownAs(target, fi); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, fileMode(fi.Mode())); err != nil {
+			return err
+		}
+		return os.Chtimes(target, fi.ModTime(), fi.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	// the mode and times of directories are set once their files are
+	// copied, children first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, fileMode(d.fi.Mode())); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.fi.ModTime(), d.fi.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileMode returns the permissions of m with its setuid, setgid and sticky
+// bits, the bits set by chmod. Files are chowned first, which clears them.
+func fileMode(m fs.FileMode) fs.FileMode {
+	return m & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
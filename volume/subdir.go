@@ -0,0 +1,293 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/store"
+)
+
+// Isolation modes of SubdirOptions.
+const (
+	// IsolateByID mounts a subdirectory per mount ID.
+	IsolateByID = "id"
+	// IsolateByLabel mounts the subdirectory named by the label option.
+	IsolateByLabel = "label"
+)
+
+// Retention policies of SubdirOptions.
+const (
+	// RetentionDelete removes the subdirectory of a mount on its last
+	// unmount.
+	RetentionDelete = "delete"
+	// RetentionKeep keeps the subdirectories of mounts.
+	RetentionKeep = "keep"
+	// RetentionDeleteEmpty only removes the subdirectory of a mount on its
+	// last unmount when it is empty.
+	RetentionDeleteEmpty = "delete-empty"
+)
+
+// SubdirOptions are the options of the volumes of the drivers returned by
+// NewSubdirDriver, taken out of the options of the volumes they create.
+type SubdirOptions struct {
+	Isolation string `opt:"subdir" values:"id,label" help:"mount a subdirectory of the volume per mount ID or label, the whole volume by default"`
+	Label     string `opt:"subdir-label" help:"subdirectory mounted with subdir=label"`
+	Retention string `opt:"subdir-retention" values:"delete,keep,delete-empty" help:"subdirectories kept after their last unmount, delete with subdir=id and keep with subdir=label by default"`
+}
+
+// SubdirOptionsSchema is the schema of SubdirOptions, to list them in the
+// documentation of drivers.
+var SubdirOptionsSchema = MustOptionsSchema(SubdirOptions{})
+
+// subdirVolume is an isolated volume.
+type subdirVolume struct {
+	SubdirOptions
+	// Mounts are the subdirectories of the active mounts by mount ID.
+	Mounts map[string]string `json:",omitempty"`
+}
+
+// subdirDriver mounts subdirectories of isolated volumes.
+type subdirDriver struct {
+	Driver
+
+	// mu serializes the mounts and unmounts, so that the subdirectories
+	// created and removed match the mounts stored.
+	mu      sync.Mutex
+	volumes *store.Store[subdirVolume]
+}
+
+// NewSubdirDriver returns a Driver isolating the mounts of the volumes of
+// driver created with the subdir option in subdirectories of the volumes,
+// see SubdirOptions. Volumes created with subdir=id give every mount its
+// own subdirectory, named by its ID, and volumes created with subdir=label
+// the subdirectory named by their subdir-label option. Path and Get still
+// return the whole volume, and the status of the volume lists the
+// subdirectories mounted.
+//
+// Every mount is forwarded to driver, see NewRefCountDriver to only forward
+// the first one. The subdirectories are created with the owner and mode of
+// the volume, and removed after their last unmount, depending on the
+// subdir-retention option. The isolated volumes are saved to a store at
+// path.
+func NewSubdirDriver(driver Driver, path string) (Driver, error) {
+	volumes, err := store.Open[subdirVolume](path, store.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &subdirDriver{Driver: driver, volumes: volumes}, nil
+}
+
+func (d *subdirDriver) Create(req *CreateRequest) error {
+	opts, forward := SubdirOptionsSchema.Split(req.Options)
+	if opts == nil {
+		return d.Driver.Create(req)
+	}
+	var v subdirVolume
+	if err := SubdirOptionsSchema.Decode(opts, &v.SubdirOptions); err != nil {
+		return err
+	}
+	if err := v.check(); err != nil {
+		return sdk.InvalidArgument(err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fwd := *req
+	fwd.Options = forward
+	if err := d.Driver.Create(&fwd); err != nil {
+		return err
+	}
+	if _, ok := d.volumes.Get(req.Name); ok {
+		return nil
+	}
+	return d.volumes.Put(req.Name, v)
+}
+
+// check checks the options of v, and sets their defaults.
+func (v *subdirVolume) check() error {
+	switch {
+	case v.Isolation == "":
+		return errors.New("subdir options without subdir option")
+	case v.Isolation != IsolateByLabel && v.Label != "":
+		return errors.New("subdir-label option without subdir=label")
+	case v.Isolation == IsolateByLabel && v.Label == "":
+		return errors.New("missing subdir-label option with subdir=label")
+	case v.Label != "" && !validSubdir(v.Label):
+		return fmt.Errorf("subdir-label %q is not a directory name", v.Label)
+	}
+	// the subdirectories of mount IDs are never mounted again
+	if v.Retention == "" {
+		v.Retention = RetentionKeep
+		if v.Isolation == IsolateByID {
+			v.Retention = RetentionDelete
+		}
+	}
+	return nil
+}
+
+// validSubdir returns whether name is the name of a subdirectory.
+func validSubdir(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+func (d *subdirDriver) Remove(req *RemoveRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if v, ok := d.volumes.Get(req.Name); ok && len(v.Mounts) > 0 {
+		return sdk.Conflict(fmt.Errorf("volume %s is in use by %d mounts", req.Name, len(v.Mounts)))
+	}
+	if err := d.Driver.Remove(req); err != nil {
+		return err
+	}
+	return d.volumes.Delete(req.Name)
+}
+
+func (d *subdirDriver) Get(req *GetRequest) (*GetResponse, error) {
+	res, err := d.Driver.Get(req)
+	if err != nil || res == nil || res.Volume == nil {
+		return res, err
+	}
+	v, ok := d.volumes.Get(req.Name)
+	if !ok {
+		return res, nil
+	}
+	subdirs := make(map[string]string, len(v.Mounts))
+	for id, dir := range v.Mounts {
+		subdirs[id] = filepath.Base(dir)
+	}
+	status := map[string]interface{}{"Isolation": v.Isolation, "SubMounts": subdirs}
+	return &GetResponse{Volume: withStatus(res.Volume, status)}, nil
+}
+
+func (d *subdirDriver) Mount(req *MountRequest) (*MountResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.volumes.Get(req.Name)
+	if !ok {
+		return d.Driver.Mount(req)
+	}
+	if dir, ok := v.Mounts[req.ID]; ok {
+		return &MountResponse{Mountpoint: dir}, nil
+	}
+	name := v.Label
+	if v.Isolation == IsolateByID {
+		if !validSubdir(req.ID) {
+			return nil, sdk.InvalidArgument(fmt.Errorf("mount ID %q is not a directory name", req.ID))
+		}
+		name = req.ID
+	}
+
+	res, err := d.Driver.Mount(req)
+	if err != nil {
+		return nil, err
+	}
+	dir, created, err := mountSubdir(res, name)
+	if err == nil {
+		// Get reads the stored mounts without mu, they are copied
+		mounts := make(map[string]string, len(v.Mounts)+1)
+		for id, dir := range v.Mounts {
+			mounts[id] = dir
+		}
+		mounts[req.ID] = dir
+		v.Mounts = mounts
+		if err = d.volumes.Put(req.Name, v); err != nil && created {
+			os.Remove(dir)
+		}
+	}
+	if err != nil {
+		d.Driver.Unmount(&UnmountRequest{Name: req.Name, ID: req.ID})
+		return nil, err
+	}
+	return &MountResponse{Mountpoint: dir}, nil
+}
+
+// mountSubdir creates the subdirectory name of the mountpoint of res, with
+// the owner and mode of the mountpoint, and returns it, and whether it was
+// created rather than already there.
+func mountSubdir(res *MountResponse, name string) (string, bool, error) {
+	if res == nil || res.Mountpoint == "" {
+		return "", false, errors.New("volume mounted without mountpoint")
+	}
+	fi, err := os.Stat(res.Mountpoint)
+	if err != nil {
+		return "", false, err
+	}
+	dir := filepath.Join(res.Mountpoint, name)
+	if err := os.Mkdir(dir, fi.Mode().Perm()); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return "", false, err
+		}
+		// subdirectories may be replaced by mounts of the whole volume
+		if sub, err := os.Lstat(dir); err != nil || !sub.IsDir() {
+			return "", false, fmt.Errorf("%s is not a directory", dir)
+		}
+		return dir, false, nil
+	}
+	err = os.Chmod(dir, fi.Mode().Perm())
+	if err == nil {
+		err = chownAs(dir, fi)
+	}
+	if err != nil {
+		os.Remove(dir)
+		return "", false, err
+	}
+	return dir, true, nil
+}
+
+func (d *subdirDriver) Unmount(req *UnmountRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.volumes.Get(req.Name)
+	if !ok {
+		return d.Driver.Unmount(req)
+	}
+	dir, ok := v.Mounts[req.ID]
+	if !ok {
+		return sdk.NotFound(fmt.Errorf("volume %s is not mounted by %s", req.Name, req.ID))
+	}
+
+	// the subdirectory is removed while the volume is still mounted
+	last := true
+	for id, other := range v.Mounts {
+		if id != req.ID && other == dir {
+			last = false
+		}
+	}
+	if last {
+		if err := retain(dir, v.Retention); err != nil {
+			return err
+		}
+	}
+	if err := d.Driver.Unmount(req); err != nil {
+		return err
+	}
+	mounts := make(map[string]string, len(v.Mounts))
+	for id, dir := range v.Mounts {
+		if id != req.ID {
+			mounts[id] = dir
+		}
+	}
+	v.Mounts = mounts
+	return d.volumes.Put(req.Name, v)
+}
+
+// retain removes the subdirectory dir after its last unmount, depending on
+// the retention policy.
+func retain(dir, retention string) error {
+	switch retention {
+	case RetentionKeep:
+		return nil
+	case RetentionDeleteEmpty:
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return nil
+		}
+		return os.Remove(dir)
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package volume
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownAs sets the owner of path to the owner of the file of fi.
+func chownAs(path string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return lchown(path, int(st.Uid), int(st.Gid))
+}
+
+// lchown sets the owner of path, not following symbolic links.
+func lchown(path string, uid, gid int) error {
+	return os.Lchown(path, uid, gid)
+}
@@ -0,0 +1,13 @@
+package volume
+
+import "os"
+
+// chownAs does nothing, files have no owner ID on Windows.
+func chownAs(path string, fi os.FileInfo) error {
+	return nil
+}
+
+// lchown does nothing, files have no owner ID on Windows.
+func lchown(path string, uid, gid int) error {
+	return nil
+}
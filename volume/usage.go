@@ -0,0 +1,438 @@
+package volume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/sdk"
+	"github.com/docker/go-plugins-helpers/sdk/store"
+)
+
+const (
+	// DefaultUsageInterval is the interval between scans of the volumes by
+	// default.
+	DefaultUsageInterval = time.Minute
+	// DefaultUsageConcurrency is the number of volumes scanned at once by
+	// default.
+	DefaultUsageConcurrency = 4
+)
+
+// Limits are limits of the usage of a volume. Zero limits are unlimited.
+type Limits struct {
+	Bytes  Size
+	Inodes int64
+}
+
+// exceeded returns whether u exceeds the limits.
+func (l Limits) exceeded(u Usage) bool {
+	return (l.Bytes > 0 && u.Bytes > int64(l.Bytes)) || (l.Inodes > 0 && u.Inodes > l.Inodes)
+}
+
+// Usage is the usage of a volume, measured by a scan.
+type Usage struct {
+	// Bytes is the size of the files of the volume.
+	Bytes int64
+	// Inodes is the number of files of the volume, directories included.
+	Inodes int64
+	// ScannedAt is the end of the scan.
+	ScannedAt time.Time
+}
+
+// QuotaState is the state of the usage of a volume relative to its limits.
+type QuotaState int
+
+const (
+	// QuotaOK is the state of volumes within their limits.
+	QuotaOK QuotaState = iota
+	// QuotaSoftExceeded is the state of volumes exceeding their soft
+	// limits.
+	QuotaSoftExceeded
+	// QuotaHardExceeded is the state of volumes exceeding their hard
+	// limits, refused new mounts.
+	QuotaHardExceeded
+)
+
+func (s QuotaState) String() string {
+	switch s {
+	case QuotaOK:
+		return "ok"
+	case QuotaSoftExceeded:
+		return "soft limit exceeded"
+	case QuotaHardExceeded:
+		return "hard limit exceeded"
+	}
+	return fmt.Sprintf("QuotaState(%d)", int(s))
+}
+
+// UsageEvent reports the change of the quota state of a volume.
+type UsageEvent struct {
+	Volume   string
+	Usage    Usage
+	State    QuotaState
+	Previous QuotaState
+}
+
+// QuotaOptions are the options of the volumes of the drivers returned by
+// NewUsageDriver, taken out of the options of the volumes they create. The
+// limits not set are the ones of UsageOptions.
+type QuotaOptions struct {
+	SoftBytes  Size  `opt:"quota-soft-size" help:"size of the volume reported when exceeded"`
+	SoftInodes int64 `opt:"quota-soft-inodes" help:"number of files of the volume reported when exceeded"`
+	HardBytes  Size  `opt:"quota-hard-size" help:"size of the volume refused new mounts when exceeded"`
+	HardInodes int64 `opt:"quota-hard-inodes" help:"number of files of the volume refused new mounts when exceeded"`
+}
+
+// QuotaOptionsSchema is the schema of QuotaOptions, to list them in the
+// documentation of drivers.
+var QuotaOptionsSchema = MustOptionsSchema(QuotaOptions{})
+
+// UsageOptions configure a UsageDriver.
+type UsageOptions struct {
+	// Interval is the interval between scans, DefaultUsageInterval when
+	// unset.
+	Interval time.Duration
+	// Concurrency is the number of volumes scanned at once,
+	// DefaultUsageConcurrency when unset.
+	Concurrency int
+	// SoftLimits are the limits reported by events when exceeded, by
+	// default for the volumes created without quota options.
+	SoftLimits Limits
+	// HardLimits are the limits of the volumes refused new mounts when
+	// exceeded, by default for the volumes created without quota options.
+	HardLimits Limits
+	// Events receives the changes of quota states, from the goroutine
+	// scanning. They are logged when unset.
+	Events func(UsageEvent)
+}
+
+// volumeUsage is the last usage measured of a volume.
+type volumeUsage struct {
+	usage Usage
+	state QuotaState
+}
+
+// UsageDriver is a Driver accounting for the usage of the volumes of a
+// driver, see NewUsageDriver.
+type UsageDriver struct {
+	Driver
+
+	opts   UsageOptions
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// scan serializes the scans
+	scan sync.Mutex
+
+	mu      sync.RWMutex
+	volumes map[string]volumeUsage
+	// removed are the volumes removed since the start of the last scan,
+	// whose usage it measured is dropped.
+	removed map[string]bool
+
+	quotas *store.Store[QuotaOptions]
+}
+
+// NewUsageDriver returns a Driver measuring the bytes and inodes used by
+// the volumes of driver, by scanning their mountpoints every interval. The
+// usage and quota state of the volumes last scanned are reported in their
+// status by Get and List, as DiskUsage and QuotaState. Volumes exceeding
+// the hard limits are refused new mounts until scanned within them again.
+//
+// The limits of volumes are the ones of opts, or the ones of their quota
+// options, see QuotaOptions, saved to a store at path.
+//
+// Scanning works on any filesystem, but is only as accurate as the last
+// scan, and counts files linked more than once at every link. The volumes
+// of driver without mountpoint are skipped. Close stops the scans.
+func NewUsageDriver(driver Driver, path string, opts UsageOptions) (*UsageDriver, error) {
+	quotas, err := store.Open[QuotaOptions](path, store.Options{})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultUsageInterval
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultUsageConcurrency
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &UsageDriver{
+		Driver:  driver,
+		opts:    opts,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		volumes: make(map[string]volumeUsage),
+		removed: make(map[string]bool),
+		quotas:  quotas,
+	}
+	go d.run(ctx)
+	return d, nil
+}
+
+func (d *UsageDriver) run(ctx context.Context) {
+	defer close(d.done)
+	t := time.NewTicker(d.opts.Interval)
+	defer t.Stop()
+	for {
+		if err := d.Scan(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("volume: scanning usage: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Close stops the scans, waiting for the current one.
+func (d *UsageDriver) Close() error {
+	d.cancel()
+	<-d.done
+	return nil
+}
+
+// Scan measures the usage of the volumes now, and returns once they are
+// all scanned. Volumes failing to be scanned are logged, and keep their
+// last usage.
+func (d *UsageDriver) Scan(ctx context.Context) error {
+	d.scan.Lock()
+	defer d.scan.Unlock()
+	d.mu.Lock()
+	d.removed = make(map[string]bool)
+	d.mu.Unlock()
+	list, err := d.Driver.List()
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return errors.New("volumes listed without response")
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.opts.Concurrency)
+	listed := make(map[string]bool)
+	for _, v := range list.Volumes {
+		if v == nil {
+			continue
+		}
+		listed[v.Name] = true
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(v Volume) {
+			defer func() { <-sem; wg.Done() }()
+			d.scanVolume(ctx, v)
+		}(*v)
+	}
+	wg.Wait()
+
+	// forget the volumes removed by other means than Remove
+	d.mu.Lock()
+	for name := range d.volumes {
+		if !listed[name] {
+			delete(d.volumes, name)
+		}
+	}
+	d.mu.Unlock()
+	return ctx.Err()
+}
+
+func (d *UsageDriver) scanVolume(ctx context.Context, v Volume) {
+	mountpoint := v.Mountpoint
+	if mountpoint == "" {
+		res, err := d.Driver.Path(&PathRequest{Name: v.Name})
+		if err != nil {
+			log.Printf("volume: scanning usage of %s: %v", v.Name, err)
+			return
+		}
+		if res == nil || res.Mountpoint == "" {
+			return
+		}
+		mountpoint = res.Mountpoint
+	}
+	usage, err := scanUsage(ctx, mountpoint)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("volume: scanning usage of %s: %v", v.Name, err)
+		}
+		return
+	}
+
+	state := QuotaOK
+	soft, hard := d.limits(v.Name)
+	if hard.exceeded(usage) {
+		state = QuotaHardExceeded
+	} else if soft.exceeded(usage) {
+		state = QuotaSoftExceeded
+	}
+	d.mu.Lock()
+	if d.removed[v.Name] {
+		d.mu.Unlock()
+		return
+	}
+	previous := d.volumes[v.Name].state
+	d.volumes[v.Name] = volumeUsage{usage: usage, state: state}
+	d.mu.Unlock()
+
+	if state != previous {
+		e := UsageEvent{Volume: v.Name, Usage: usage, State: state, Previous: previous}
+		if d.opts.Events != nil {
+			d.opts.Events(e)
+		} else {
+			log.Printf("volume: %s: %s, using %d bytes and %d inodes", v.Name, state, usage.Bytes, usage.Inodes)
+		}
+	}
+}
+
+// limits returns the soft and hard limits of the volume name.
+func (d *UsageDriver) limits(name string) (Limits, Limits) {
+	soft, hard := d.opts.SoftLimits, d.opts.HardLimits
+	q, _ := d.quotas.Get(name)
+	if q.SoftBytes > 0 {
+		soft.Bytes = q.SoftBytes
+	}
+	if q.SoftInodes > 0 {
+		soft.Inodes = q.SoftInodes
+	}
+	if q.HardBytes > 0 {
+		hard.Bytes = q.HardBytes
+	}
+	if q.HardInodes > 0 {
+		hard.Inodes = q.HardInodes
+	}
+	return soft, hard
+}
+
+// scanUsage returns the usage of the files under dir. The files removed
+// while scanning are skipped, volumes in use change as they are scanned.
+func scanUsage(ctx context.Context, dir string) (Usage, error) {
+	var u Usage
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Type().IsRegular() {
+			fi, err := e.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			u.Bytes += fi.Size()
+		}
+		u.Inodes++
+		return nil
+	})
+	u.ScannedAt = time.Now().UTC()
+	return u, err
+}
+
+// Usage returns the last usage measured of the volume name, and its quota
+// state, or false when it wasn't scanned.
+func (d *UsageDriver) Usage(name string) (Usage, QuotaState, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	v, ok := d.volumes[name]
+	return v.usage, v.state, ok
+}
+
+// status returns a copy of the volume v with its usage in its status.
+func (d *UsageDriver) status(v *Volume) *Volume {
+	usage, state, ok := d.Usage(v.Name)
+	if !ok {
+		return v
+	}
+	return withStatus(v, map[string]interface{}{"DiskUsage": usage, "QuotaState": state.String()})
+}
+
+// Get returns the volume of driver, with its usage.
+func (d *UsageDriver) Get(req *GetRequest) (*GetResponse, error) {
+	res, err := d.Driver.Get(req)
+	if err != nil || res == nil || res.Volume == nil {
+		return res, err
+	}
+	return &GetResponse{Volume: d.status(res.Volume)}, nil
+}
+
+// List returns the volumes of driver, with their usage.
+func (d *UsageDriver) List() (*ListResponse, error) {
+	res, err := d.Driver.List()
+	if err != nil || res == nil {
+		return res, err
+	}
+	vols := make([]*Volume, len(res.Volumes))
+	for i, v := range res.Volumes {
+		if v != nil {
+			v = d.status(v)
+		}
+		vols[i] = v
+	}
+	return &ListResponse{Volumes: vols}, nil
+}
+
+// Mount refuses to mount volumes exceeding the hard limits.
+func (d *UsageDriver) Mount(req *MountRequest) (*MountResponse, error) {
+	if usage, state, _ := d.Usage(req.Name); state == QuotaHardExceeded {
+		return nil, sdk.Conflict(fmt.Errorf("volume %s exceeds its hard limits, using %d bytes and %d inodes", req.Name, usage.Bytes, usage.Inodes))
+	}
+	return d.Driver.Mount(req)
+}
+
+// Create creates the volume of driver, with the limits of its quota
+// options.
+func (d *UsageDriver) Create(req *CreateRequest) error {
+	opts, forward := QuotaOptionsSchema.Split(req.Options)
+	if opts == nil {
+		return d.Driver.Create(req)
+	}
+	var q QuotaOptions
+	if err := QuotaOptionsSchema.Decode(opts, &q); err != nil {
+		return err
+	}
+	if q.SoftInodes < 0 || q.HardInodes < 0 {
+		return sdk.InvalidArgument(errors.New("negative quota of inodes"))
+	}
+	fwd := *req
+	fwd.Options = forward
+	if err := d.Driver.Create(&fwd); err != nil {
+		return err
+	}
+	// creating an existing volume keeps its limits
+	if _, ok := d.quotas.Get(req.Name); ok {
+		return nil
+	}
+	return d.quotas.Put(req.Name, q)
+}
+
+// Remove removes the volume of driver, and forgets its usage and limits.
+func (d *UsageDriver) Remove(req *RemoveRequest) error {
+	if err := d.Driver.Remove(req); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	delete(d.volumes, req.Name)
+	d.removed[req.Name] = true
+	d.mu.Unlock()
+	if _, ok := d.quotas.Get(req.Name); !ok {
+		return nil
+	}
+	return d.quotas.Delete(req.Name)
+}